@@ -2,10 +2,12 @@ package command
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/urfave/cli"
 
 	"github.com/smallstep/cli-utils/step"
+	"github.com/smallstep/cli-utils/ui"
 	"github.com/smallstep/cli-utils/usage"
 )
 
@@ -19,10 +21,32 @@ func init() {
 	}
 }
 
+type registerOptions struct {
+	envVarOptions []step.EnvVarOption
+}
+
+// RegisterOption is the type of the functions that modify how a command is
+// registered.
+type RegisterOption func(*registerOptions)
+
+// WithScopedEnvVars makes the flags of the command and its subcommands read
+// first from an environment variable scoped to the command, like
+// STEP_CA_CERTIFICATE_KTY for the flag --kty of 'ca certificate', and then
+// from the global one, STEP_KTY.
+func WithScopedEnvVars() RegisterOption {
+	return func(o *registerOptions) {
+		o.envVarOptions = append(o.envVarOptions, step.WithScopedEnvVars(true))
+	}
+}
+
 // Register adds the given command to the global list of commands.
 // It sets recursively the command Flags environment variables.
-func Register(c cli.Command) {
-	step.SetEnvVar(&c)
+func Register(c cli.Command, opts ...RegisterOption) {
+	o := new(registerOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+	step.SetEnvVar(&c, o.envVarOptions...)
 	cmds = append(cmds, c)
 }
 
@@ -43,3 +67,104 @@ func ActionFunc(fn cli.ActionFunc) cli.ActionFunc {
 func IsForce() bool {
 	return currentContext != nil && currentContext.Bool("force")
 }
+
+// DryRunEnv defines the name of the environment variable that enables the
+// dry-run mode without the dry-run flag.
+const DryRunEnv = "STEP_DRY_RUN"
+
+// DryRunFlag is the flag that enables the dry-run mode. In dry-run mode, the
+// fileutil write functions print the diffs of the changes to the standard
+// error instead of modifying the files. It can be added to the global flags of
+// an application or to the flags of a command.
+var DryRunFlag = cli.BoolFlag{
+	Name:   "dry-run",
+	Usage:  `Print the changes to the files without modifying them.`,
+	EnvVar: DryRunEnv,
+}
+
+// IsDryRun returns if the dry-run flag was passed, as a command or a global
+// flag, or if the environment variable STEP_DRY_RUN is set to true.
+func IsDryRun() bool {
+	if b, err := strconv.ParseBool(os.Getenv(DryRunEnv)); err == nil && b {
+		return true
+	}
+	return currentContext != nil && (currentContext.Bool("dry-run") || currentContext.GlobalBool("dry-run"))
+}
+
+// Name returns the full name of the command being run, like "step ssh config",
+// or an empty string if the command is not known.
+func Name() string {
+	if currentContext == nil {
+		return ""
+	}
+	if currentContext.Command.HelpName != "" {
+		return currentContext.Command.HelpName
+	}
+	return currentContext.Command.FullName()
+}
+
+// NoPromptFlag is the flag that disables all the prompts, so commands fail
+// instead of waiting for user input. It can be added to the global flags of an
+// application or to the flags of a command. Prompts are also disabled if the
+// CI environment variable is set to a true value, like "true" or "1".
+var NoPromptFlag = cli.BoolFlag{
+	Name: "no-prompt",
+	Usage: `Disable all the prompts. A command that needs a value that is not provided
+fails instead of asking for it. Prompts are also disabled if the **CI**
+environment variable is set to true.`,
+	EnvVar: ui.NoPromptEnv,
+}
+
+// OverwriteEnv defines the name of the environment variable that sets the
+// policy used when a file to write already exists.
+const OverwriteEnv = "STEP_OVERWRITE"
+
+// OverwriteFlag is the flag that sets the policy used when a file to write
+// already exists. It can be added to the global flags of an application or to
+// the flags of a command.
+var OverwriteFlag = cli.StringFlag{
+	Name: "overwrite",
+	Usage: `The <policy> used when a file to write already exists. The options are:
+
+    **prompt**
+    :  Ask before overwriting the file (default).
+
+    **never**
+    :  Never overwrite the file, fail instead.
+
+    **overwrite**
+    :  Overwrite the file without asking.
+
+    **backup**
+    :  Copy the existing file to a sibling with the .bak extension and overwrite it.
+
+    **new**
+    :  Keep the existing file and write the new one with the .new extension, or
+    .new.N if it exists. The existing file is not updated, so the command fails
+    with the name of the new file.`,
+	EnvVar: OverwriteEnv,
+}
+
+// OverwritePolicy returns the overwrite policy set with the overwrite flag, as
+// a command or a global flag, "overwrite" if the force flag was passed, or the
+// value of the environment variable STEP_OVERWRITE. It returns an empty string
+// if the policy is not set.
+//
+// The overwrite flag also reads STEP_OVERWRITE, so with the force flag, a flag
+// value equal to the environment variable is considered to come from it, and
+// the force flag takes precedence.
+func OverwritePolicy() string {
+	env := os.Getenv(OverwriteEnv)
+	force := IsForce()
+	if currentContext != nil {
+		for _, v := range []string{currentContext.String("overwrite"), currentContext.GlobalString("overwrite")} {
+			if v != "" && (!force || v != env) {
+				return v
+			}
+		}
+	}
+	if force {
+		return "overwrite"
+	}
+	return env
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/errs"
+	"github.com/smallstep/cli-utils/step"
+)
+
+func init() {
+	cmd := cli.Command{
+		Name:      "config",
+		Usage:     "manage the cli configuration",
+		UsageText: "**step config** <subcommand> [arguments] [global-flags] [subcommand-flags]",
+		Description: `**step config** command group provides facilities to manage the
+configuration files of the cli.`,
+		Subcommands: cli.Commands{
+			showCommand(),
+			validateCommand(),
+			restoreCommand(),
+		},
+	}
+
+	command.Register(cmd)
+}
+
+func showCommand() cli.Command {
+	return cli.Command{
+		Name:      "show",
+		Usage:     "print the configuration of the current context",
+		UsageText: "**step config show**",
+		Description: `**step config show** prints the configuration of the current context, the
+values of the defaults files merged with the ones of the parent profiles, in
+JSON format.
+
+The literal values of the keys that might contain secrets, like passwords or
+tokens, are redacted. References to files, environment variables or commands,
+like "env:STEP_PASSWORD", are printed as they are, and they are not resolved.
+
+## EXAMPLES
+
+Print the configuration of the current context:
+'''
+$ step config show
+'''`,
+		Action: command.ActionFunc(showAction),
+	}
+}
+
+func showAction(*cli.Context) error {
+	cfg, err := step.Contexts().GetConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		cfg = map[string]interface{}{}
+	}
+	b, err := json.MarshalIndent(step.RedactConfig(cfg), "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "error marshaling configuration")
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func validateCommand() cli.Command {
+	return cli.Command{
+		Name:      "validate",
+		Usage:     "validate the defaults configuration files",
+		UsageText: "**step config validate** [<file>...]",
+		Description: `**step config validate** validates the defaults configuration files
+against the flags of all the commands. It reports the keys that do not match any
+flag, the keys that cannot be set in the configuration files, and the values
+that do not match the type of the flag.
+
+If no files are given, the defaults files of the current context are validated,
+including the ones of the parent profiles.
+
+## POSITIONAL ARGUMENTS
+
+<file>
+: The path to a defaults.json file.
+
+## EXIT CODES
+
+This command returns 0 on success and \>0 if any error occurs or any of the
+files is not valid.
+
+## EXAMPLES
+
+Validate the configuration of the current context:
+'''
+$ step config validate
+'''
+
+Validate a configuration file:
+'''
+$ step config validate $(step path)/config/defaults.json
+'''`,
+		Action: command.ActionFunc(validateAction),
+	}
+}
+
+func validateAction(ctx *cli.Context) error {
+	files := []string(ctx.Args())
+	if len(files) == 0 {
+		var err error
+		if files, err = defaultsFiles(); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			fmt.Println("no configuration files found")
+			return nil
+		}
+	}
+
+	var invalid int
+	for _, fn := range files {
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			return errs.FileError(err, fn)
+		}
+		values := make(map[string]interface{})
+		if err := json.Unmarshal(b, &values); err != nil {
+			return errors.Wrapf(err, "error parsing %s", fn)
+		}
+
+		issues := Validate(values, command.Retrieve())
+		if len(issues) == 0 {
+			fmt.Printf("%s: ok\n", fn)
+			continue
+		}
+		invalid++
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", fn, issue)
+		}
+	}
+
+	if invalid > 0 {
+		return errs.NewExitError(errors.Errorf("%d of %d configuration files are not valid", invalid, len(files)), 1)
+	}
+	return nil
+}
+
+// defaultsFiles returns the defaults files of the current context that exist,
+// including the ones inherited from parent profiles.
+func defaultsFiles() ([]string, error) {
+	var candidates []string
+	if cur := step.Contexts().GetCurrent(); cur != nil {
+		profileFiles, err := cur.ProfileDefaultsFiles()
+		if err != nil {
+			return nil, err
+		}
+		candidates = append([]string{cur.DefaultsFile()}, profileFiles...)
+	} else {
+		candidates = []string{step.DefaultsFile()}
+	}
+
+	var files []string
+	for _, fn := range candidates {
+		if _, err := os.Stat(fn); err == nil {
+			files = append(files, fn)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/errs"
+	"github.com/smallstep/cli-utils/fileutil"
+)
+
+func restoreCommand() cli.Command {
+	return cli.Command{
+		Name:      "restore",
+		Usage:     "roll back the last modifications of files made by the cli",
+		UsageText: "**step config restore** [**--last**=<number>] [**--list**] [**--dry-run**] [**--force**]",
+		Description: `**step config restore** rolls back the last modifications of files made
+by the cli to configuration files, like the ssh configuration files. Before a
+configuration file is edited, a copy of its content is stored in the backups
+directory, and the change is recorded in a manifest with the command that made
+it. Other files, like private keys or certificates, are not backed up, and only
+the last 100 modifications are kept. Modified files are restored to
+their previous content, and created files are removed.
+
+If a file has been modified after the recorded change, you will be asked before
+discarding those modifications.
+
+## EXAMPLES
+
+List the modifications that can be rolled back:
+'''
+$ step config restore --list
+'''
+
+Roll back the last modification:
+'''
+$ step config restore
+'''
+
+Show the changes needed to roll back the last three modifications:
+'''
+$ step config restore --last 3 --dry-run
+'''`,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "last",
+				Usage: `The <number> of modifications to roll back.`,
+				Value: 1,
+			},
+			cli.BoolFlag{
+				Name:  "list",
+				Usage: `List the modifications that can be rolled back, from the newest to the oldest.`,
+			},
+			command.DryRunFlag,
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: `Restore files modified after the recorded change without asking.`,
+			},
+		},
+		Action: command.ActionFunc(restoreAction),
+	}
+}
+
+func restoreAction(ctx *cli.Context) error {
+	if ctx.Bool("list") {
+		backups, err := fileutil.Backups()
+		if err != nil {
+			return err
+		}
+		for i := len(backups) - 1; i >= 0; i-- {
+			printBackup(backups[i])
+		}
+		return nil
+	}
+
+	n := ctx.Int("last")
+	if n <= 0 {
+		return errs.InvalidFlagValueMsg(ctx, "last", ctx.String("last"), "the number must be greater than zero")
+	}
+	restored, err := fileutil.Restore(n)
+	if !fileutil.IsDryRun() {
+		for _, b := range restored {
+			printBackup(b)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(restored) == 0 {
+		fmt.Println("no modifications to restore")
+	}
+	return nil
+}
+
+func printBackup(b fileutil.Backup) {
+	action := "modified"
+	if b.BackupFile == "" {
+		action = "created"
+	}
+	cmd := b.Command
+	if cmd == "" {
+		cmd = "unknown command"
+	}
+	fmt.Printf("%s %s %s by %s\n", b.Time.Local().Format("2006-01-02 15:04:05"), b.Filename, action, cmd)
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/step"
+)
+
+// Issue is a problem found in a configuration file.
+type Issue struct {
+	Key     string
+	Message string
+}
+
+// String implements fmt.Stringer.
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Key, i.Message)
+}
+
+// Validate validates the given configuration values, as stored in a
+// defaults.json file, against the flags of the given commands and their
+// subcommands. It reports the keys that are not a flag of any command, the
+// keys that cannot be set in the configuration files, and the values that
+// cannot be parsed by any of the flags with the key name. The issues are
+// sorted by key.
+func Validate(values map[string]interface{}, cmds []cli.Command) []Issue {
+	flags := make(map[string][]cli.Flag)
+	var walk func([]cli.Command)
+	walk = func(cmds []cli.Command) {
+		for i := range cmds {
+			for _, f := range cmds[i].Flags {
+				for _, name := range strings.Split(f.GetName(), ",") {
+					name = strings.TrimSpace(name)
+					flags[name] = append(flags[name], f)
+				}
+			}
+			walk(cmds[i].Subcommands)
+		}
+	}
+	walk(cmds)
+
+	banned := make(map[string]bool)
+	for _, attr := range step.BannedConfigAttributes() {
+		banned[attr] = true
+	}
+
+	var issues []Issue
+	for key, value := range values {
+		fs, ok := flags[key]
+		switch {
+		case banned[key]:
+			issues = append(issues, Issue{key, "cannot be set in configuration files"})
+		case !ok:
+			issues = append(issues, Issue{key, "unknown key; it does not match any flag"})
+		case allIgnored(fs):
+			issues = append(issues, Issue{key, fmt.Sprintf("flag '--%s' cannot be set in configuration files", key)})
+		default:
+			if err := checkValue(fs, value); err != nil {
+				issues = append(issues, Issue{key, err.Error()})
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].Key < issues[j].Key
+	})
+	return issues
+}
+
+// allIgnored returns true if none of the given flags can be set in
+// configuration files.
+func allIgnored(fs []cli.Flag) bool {
+	for _, f := range fs {
+		if step.FlagEnvVar(f) != step.IgnoreEnvVar {
+			return false
+		}
+	}
+	return true
+}
+
+// checkValue returns an error if the value cannot be used by any of the given
+// flags.
+func checkValue(fs []cli.Flag, value interface{}) (err error) {
+	for _, f := range fs {
+		if step.FlagEnvVar(f) == step.IgnoreEnvVar {
+			continue
+		}
+		if err = checkFlagValue(f, value); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkFlagValue returns an error if the value cannot be parsed by the given
+// flag. Values are set in the flags using their string representation, so a
+// string with the right format is always valid.
+func checkFlagValue(f cli.Flag, value interface{}) error {
+	// References are resolved when the configuration is applied.
+	if step.IsValueReference(value) {
+		return nil
+	}
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case bool, float64:
+		s = step.FormatValue(v)
+	case nil:
+		return errors.New("invalid value null")
+	default:
+		return fmt.Errorf("invalid value %v; expected a single value", v)
+	}
+
+	// Pointers to flags are validated as the flags they point to.
+	if v := reflect.ValueOf(f); v.Kind() == reflect.Ptr && !v.IsNil() {
+		if ef, ok := v.Elem().Interface().(cli.Flag); ok {
+			f = ef
+		}
+	}
+
+	var err error
+	switch f.(type) {
+	case cli.BoolFlag, cli.BoolTFlag:
+		_, err = strconv.ParseBool(s)
+		return wantType(err, "a boolean", value)
+	case cli.DurationFlag:
+		_, err = time.ParseDuration(s)
+		return wantType(err, "a duration", value)
+	case cli.Float64Flag:
+		_, err = strconv.ParseFloat(s, 64)
+		return wantType(err, "a number", value)
+	case cli.IntFlag, cli.Int64Flag, cli.IntSliceFlag, cli.Int64SliceFlag:
+		err = parseInt(value, s, true)
+		return wantType(err, "an integer", value)
+	case cli.UintFlag, cli.Uint64Flag:
+		err = parseInt(value, s, false)
+		return wantType(err, "a non-negative integer", value)
+	default:
+		return nil
+	}
+}
+
+func parseInt(value interface{}, s string, signed bool) error {
+	if n, ok := value.(float64); ok {
+		if n != math.Trunc(n) || (!signed && n < 0) {
+			return strconv.ErrSyntax
+		}
+		return nil
+	}
+	var err error
+	if signed {
+		_, err = strconv.ParseInt(s, 0, 64)
+	} else {
+		_, err = strconv.ParseUint(s, 0, 64)
+	}
+	return err
+}
+
+func wantType(err error, typ string, value interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid value %#v; expected %s", value, typ)
+}
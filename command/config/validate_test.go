@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/step"
+)
+
+func TestValidate(t *testing.T) {
+	cmds := []cli.Command{
+		{
+			Name: "ca",
+			Subcommands: []cli.Command{
+				{
+					Name: "certificate",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "ca-url"},
+						cli.DurationFlag{Name: "not-after"},
+						cli.BoolFlag{Name: "force, f"},
+						cli.IntFlag{Name: "size"},
+						&cli.IntFlag{Name: "timeout"},
+						cli.StringFlag{Name: "password-file", EnvVar: step.IgnoreEnvVar},
+					},
+				},
+				{
+					Name: "token",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "not-after"},
+						cli.UintFlag{Name: "port"},
+					},
+				},
+			},
+		},
+	}
+
+	values := map[string]interface{}{
+		"ca-url":        "https://ca.local",
+		"not-after":     "tomorrow",
+		"f":             "yes",
+		"force":         true,
+		"size":          2048.0,
+		"timeout":       "never",
+		"port":          -1.0,
+		"password-file": "/tmp/pass",
+		"root-ca":       "root_ca.crt",
+		"context":       "ca.local",
+		"ca-url-list":   []interface{}{"a", "b"},
+	}
+
+	assert.Equal(t, []Issue{
+		{"ca-url-list", "unknown key; it does not match any flag"},
+		{"context", "cannot be set in configuration files"},
+		{"f", `invalid value "yes"; expected a boolean`},
+		{"password-file", "flag '--password-file' cannot be set in configuration files"},
+		{"port", "invalid value -1; expected a non-negative integer"},
+		{"root-ca", "unknown key; it does not match any flag"},
+		{"timeout", `invalid value "never"; expected an integer`},
+	}, Validate(values, cmds))
+
+	values = map[string]interface{}{
+		"ca-url":  []interface{}{"a", "b"},
+		"size":    "0x10",
+		"timeout": 1000000.0,
+		"port":    "env:STEP_PORT",
+	}
+	assert.Equal(t, []Issue{
+		{"ca-url", "invalid value [a b]; expected a single value"},
+	}, Validate(values, cmds))
+}
@@ -0,0 +1,333 @@
+package version
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+const (
+	// DefaultReleaseURL is the default URL of the release manifest used to
+	// check for updates.
+	DefaultReleaseURL = "https://api.github.com/repos/smallstep/cli/releases/latest"
+
+	// NoUpdateCheckEnv defines the name of the environment variable that
+	// disables the update check.
+	NoUpdateCheckEnv = "STEP_NO_UPDATE_CHECK"
+
+	// DefaultUpdateCheckTTL is the default time the result of an update check
+	// is cached.
+	DefaultUpdateCheckTTL = 24 * time.Hour
+)
+
+// Release is the latest release described by a release manifest. A release
+// manifest is a JSON document with the version and, optionally, the URL of the
+// release:
+//
+//	{"version": "0.28.0", "url": "https://example.com/releases/0.28.0"}
+//
+// The format of the GitHub latest release API, using the fields tag_name and
+// html_url, is also supported.
+type Release struct {
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler and supports both the release
+// manifest and the GitHub release formats.
+func (r *Release) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Version string `json:"version"`
+		URL     string `json:"url"`
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Version, r.URL = v.Version, v.URL
+	if r.Version == "" {
+		r.Version = v.TagName
+	}
+	if r.URL == "" {
+		r.URL = v.HTMLURL
+	}
+	return nil
+}
+
+// updateCache is the result of an update check stored in the cache file.
+type updateCache struct {
+	URL       string    `json:"url"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Release   Release   `json:"release"`
+}
+
+// UpdateChecker checks if there is a newer version available using a release
+// manifest.
+type UpdateChecker struct {
+	// URL is the location of the release manifest.
+	URL string
+	// CacheFile is the file where the result of the last check is stored. If
+	// empty, the results are not cached.
+	CacheFile string
+	// TTL is the time the result of a check is cached.
+	TTL time.Duration
+	// Client is the http client used to get the release manifest.
+	Client *http.Client
+	// FS is the file system used to read and write the cache file. If nil,
+	// the file system of the operating system is used.
+	FS fsys.FS
+
+	now func() time.Time
+}
+
+// UpdateCheckDisabled returns true if the update check has been disabled using
+// the environment variable STEP_NO_UPDATE_CHECK.
+func UpdateCheckDisabled() bool {
+	b, err := strconv.ParseBool(os.Getenv(NoUpdateCheckEnv))
+	return err == nil && b
+}
+
+// Latest returns the latest release, from the cache file if the cached result
+// is still valid, or from the release manifest.
+func (u *UpdateChecker) Latest(ctx context.Context) (*Release, error) {
+	now := time.Now
+	if u.now != nil {
+		now = u.now
+	}
+
+	if c, ok := u.readCache(); ok && c.URL == u.URL && now().Sub(c.CheckedAt) < u.TTL {
+		return &c.Release, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting %s", u.URL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error getting %s: %s", u.URL, resp.Status)
+	}
+
+	var r Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", u.URL)
+	}
+	if r.Version == "" {
+		return nil, errors.Errorf("error parsing %s: version not found", u.URL)
+	}
+
+	u.writeCache(&updateCache{
+		URL:       u.URL,
+		CheckedAt: now(),
+		Release:   r,
+	})
+	return &r, nil
+}
+
+// Check returns the latest release and true if it is newer than the given
+// version. Development versions are never outdated.
+func (u *UpdateChecker) Check(ctx context.Context, current string) (*Release, bool, error) {
+	r, err := u.Latest(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	cmp, ok := compareVersions(r.Version, current)
+	return r, ok && cmp > 0, nil
+}
+
+// fs returns the file system used for the cache file.
+func (u *UpdateChecker) fs() fsys.FS {
+	if u.FS == nil {
+		return fsys.OS
+	}
+	return u.FS
+}
+
+func (u *UpdateChecker) readCache() (*updateCache, bool) {
+	if u.CacheFile == "" {
+		return nil, false
+	}
+	b, err := u.fs().ReadFile(u.CacheFile)
+	if err != nil {
+		return nil, false
+	}
+	var c updateCache
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, false
+	}
+	return &c, true
+}
+
+// writeCache stores the result of a check. Errors are ignored, the check will
+// be done again the next time.
+func (u *UpdateChecker) writeCache(c *updateCache) {
+	if u.CacheFile == "" {
+		return
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if err := u.fs().MkdirAll(filepath.Dir(u.CacheFile), 0700); err != nil {
+		return
+	}
+	_ = fsys.WriteFileAtomic(u.fs(), u.CacheFile, b, 0600)
+}
+
+// compareVersions compares two semantic versions, with or without the "v"
+// prefix. It returns false if any of the versions cannot be parsed.
+func compareVersions(a, b string) (int, bool) {
+	va, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < 3; i++ {
+		if va.parts[i] != vb.parts[i] {
+			if va.parts[i] > vb.parts[i] {
+				return 1, true
+			}
+			return -1, true
+		}
+	}
+	// A pre-release version is lower than the release.
+	switch {
+	case va.pre == vb.pre:
+		return 0, true
+	case va.pre == "":
+		return 1, true
+	case vb.pre == "":
+		return -1, true
+	default:
+		return comparePreRelease(va.pre, vb.pre), true
+	}
+}
+
+// comparePreRelease compares two pre-release versions, like "rc.1", comparing
+// each dot-separated identifier as described in the semver specification:
+// numeric identifiers are compared numerically and have lower precedence than
+// alphanumeric ones, and a shorter list of identifiers has lower precedence if
+// all the previous ones are equal. Alphanumeric identifiers with a numeric
+// suffix and the same prefix, like "rc2" and "rc10", are compared by the
+// number, as they are commonly used.
+func comparePreRelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareIdentifier(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+func compareIdentifier(a, b string) int {
+	na, aNum := parseNumeric(a)
+	nb, bNum := parseNumeric(b)
+	switch {
+	case aNum && bNum:
+		return cmp.Compare(na, nb)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	pa, sa := splitNumericSuffix(a)
+	pb, sb := splitNumericSuffix(b)
+	if pa == pb && sa != "" && sb != "" {
+		na, _ := parseNumeric(sa)
+		nb, _ := parseNumeric(sb)
+		if c := cmp.Compare(na, nb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// parseNumeric returns the value of a numeric identifier, and true if s only
+// contains digits.
+func parseNumeric(s string) (uint64, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
+
+// splitNumericSuffix splits s in a prefix and its trailing digits.
+func splitNumericSuffix(s string) (prefix, suffix string) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	return s[:i], s[i:]
+}
+
+type semver struct {
+	parts [3]int
+	pre   string
+}
+
+func parseVersion(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.pre = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// printUpdateNotice checks for updates and prints a notice to stderr if there
+// is a newer version. Errors are printed as warnings, they never make the
+// version command fail.
+func printUpdateNotice(ctx context.Context, u *UpdateChecker, current string) {
+	r, ok, err := u.Check(ctx, current)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: cannot check for updates: %v\n", err)
+	case ok:
+		fmt.Fprintf(os.Stderr, "A new version is available: %s (current %s)\n", strings.TrimPrefix(r.Version, "v"), current)
+		if r.URL != "" {
+			fmt.Fprintf(os.Stderr, "Download it from %s\n", r.URL)
+		}
+	}
+}
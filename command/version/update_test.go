@@ -0,0 +1,118 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateChecker_Check(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/latest.json":
+			w.Write([]byte(`{"version":"0.28.1","url":"https://example.com/0.28.1"}`))
+		case "/github":
+			w.Write([]byte(`{"tag_name":"v0.29.0","html_url":"https://github.com/smallstep/cli/releases/tag/v0.29.0"}`))
+		case "/empty":
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	now := time.Now()
+	cacheFile := filepath.Join(t.TempDir(), "cache", "update-check.json")
+	u := &UpdateChecker{
+		URL:       srv.URL + "/latest.json",
+		CacheFile: cacheFile,
+		TTL:       time.Hour,
+		Client:    srv.Client(),
+		now:       func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	r, ok, err := u.Check(ctx, "0.28.0")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &Release{Version: "0.28.1", URL: "https://example.com/0.28.1"}, r)
+	assert.FileExists(t, cacheFile)
+	assert.Equal(t, 1, requests)
+
+	// Cached
+	_, ok, err = u.Check(ctx, "0.28.1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = u.Check(ctx, "0000000-dev")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, requests)
+
+	// Expired
+	now = now.Add(2 * time.Hour)
+	_, _, err = u.Check(ctx, "0.28.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	// GitHub format, the cache is not used for a different URL
+	u.URL = srv.URL + "/github"
+	r, ok, err = u.Check(ctx, "v0.28.1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v0.29.0", r.Version)
+	assert.Equal(t, 3, requests)
+
+	u.URL = srv.URL + "/empty"
+	_, _, err = u.Check(ctx, "0.28.1")
+	assert.ErrorContains(t, err, "version not found")
+
+	u.URL = srv.URL + "/missing"
+	_, _, err = u.Check(ctx, "0.28.1")
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestUpdateCheckDisabled(t *testing.T) {
+	t.Setenv(NoUpdateCheckEnv, "")
+	assert.False(t, UpdateCheckDisabled())
+	t.Setenv(NoUpdateCheckEnv, "1")
+	assert.True(t, UpdateCheckDisabled())
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"0.28.1", "0.28.0", 1, true},
+		{"v0.28.0", "0.28.0", 0, true},
+		{"0.9.0", "0.10.0", -1, true},
+		{"1.0.0", "1.0.0-rc1", 1, true},
+		{"1.0.0-rc1", "1.0.0-rc2", -1, true},
+		{"1.0.0-rc2", "1.0.0-rc10", -1, true},
+		{"1.0.0-rc10", "1.0.0-rc2", 1, true},
+		{"1.0.0-rc.9", "1.0.0-rc.10", -1, true},
+		{"1.0.0-rc.1", "1.0.0-rc.1.1", -1, true},
+		{"1.0.0-1", "1.0.0-alpha", -1, true},
+		{"1.0.0-alpha", "1.0.0-beta", -1, true},
+		{"1.0.0-beta2", "1.0.0-rc1", -1, true},
+		{"1.0.0+build", "1.0.0", 0, true},
+		{"0.28.0", "0000000-dev", 0, false},
+		{"latest", "0.28.0", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			got, ok := compareVersions(tt.a, tt.b)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
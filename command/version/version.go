@@ -1,29 +1,109 @@
 package version
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/urfave/cli"
 
 	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/errs"
 	"github.com/smallstep/cli-utils/step"
 )
 
 func init() {
 	cmd := cli.Command{
-		Name:        "version",
-		Usage:       "display the current version of the cli",
-		UsageText:   "**step version**",
-		Description: `**step version** prints the version of the cli.`,
-		Action:      Command,
+		Name:  "version",
+		Usage: "display the current version of the cli",
+		UsageText: `**step version** [**--format**=<format>]
+[**--check**] [**--release-url**=<url>]`,
+		Description: `**step version** prints the version of the cli.
+
+## EXAMPLES
+
+Print the version and build information:
+'''
+$ step version
+'''
+
+Print the version and build information, including the module dependencies,
+in JSON format:
+'''
+$ step version --format json
+'''
+
+Check if there is a newer version available:
+'''
+$ step version --check
+'''
+
+Check for updates using a custom release manifest:
+'''
+$ step version --check --release-url https://example.com/step/latest.json
+'''`,
+		Action: Command,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "format",
+				Usage: `The <format> of the output. The options are:
+
+    **text**
+    :  Print the version and build information in a human readable format (default).
+
+    **json**
+    :  Print the version, build information and module dependencies as JSON.`,
+				Value: "text",
+			},
+			cli.BoolFlag{
+				Name: "check",
+				Usage: `Check if there is a newer version available. The result of the check is
+cached for 24 hours. The check can be disabled setting the environment variable
+STEP_NO_UPDATE_CHECK to true.`,
+			},
+			cli.StringFlag{
+				Name: "release-url",
+				Usage: `The <url> of the release manifest used to check for updates. The manifest
+is a JSON document with the latest "version" and, optionally, its "url".`,
+				Value: DefaultReleaseURL,
+			},
+		},
 	}
 
 	command.Register(cmd)
 }
 
 // Command prints out the current version of the tool
-func Command(*cli.Context) error {
-	fmt.Printf("%s\n", step.Version())
-	fmt.Printf("Release Date: %s\n", step.ReleaseDate())
+func Command(ctx *cli.Context) error {
+	info := step.Info()
+	switch format := ctx.String("format"); format {
+	case "", "text":
+		fmt.Printf("%s\n", step.Version())
+		fmt.Printf("Release Date: %s\n", info.ReleaseDate)
+		if rev := info.ShortRevision(); rev != "" {
+			fmt.Printf("Revision: %s\n", rev)
+		}
+		fmt.Printf("Go Version: %s\n", info.GoVersion)
+	case "json":
+		b, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		return errs.InvalidFlagValue(ctx, "format", format, "text, json")
+	}
+
+	if ctx.Bool("check") && !UpdateCheckDisabled() {
+		c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		printUpdateNotice(c, &UpdateChecker{
+			URL:       ctx.String("release-url"),
+			CacheFile: filepath.Join(step.CachePath(), "update-check.json"),
+			TTL:       DefaultUpdateCheckTTL,
+		}, info.Version)
+	}
 	return nil
 }
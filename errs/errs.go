@@ -46,30 +46,34 @@ func Wrap(err error, format string, args ...interface{}) error {
 // InsecureCommand returns an error with a message saying that the current
 // command requires the insecure flag.
 func InsecureCommand(ctx *cli.Context) error {
-	return fmt.Errorf("'%s %s' requires the '--insecure' flag", ctx.App.Name, ctx.Command.Name)
+	return newFlagError(CodeInsecureCommand, CategoryInsecure,
+		fmt.Sprintf("'%s %s' requires the '--insecure' flag", ctx.App.Name, ctx.Command.Name), "insecure")
 }
 
 // EqualArguments returns an error saying that the given positional arguments
 // cannot be equal.
 func EqualArguments(ctx *cli.Context, arg1, arg2 string) error {
-	return fmt.Errorf("positional arguments <%s> and <%s> cannot be equal in '%s'", arg1, arg2, usage(ctx))
+	return newArgumentError(CodeEqualArguments, CategoryArguments,
+		fmt.Sprintf("positional arguments <%s> and <%s> cannot be equal in '%s'", arg1, arg2, usage(ctx)), arg1, arg2)
 }
 
 // MissingArguments returns an error with a missing arguments message for the
 // given positional argument names.
 func MissingArguments(ctx *cli.Context, argNames ...string) error {
+	var msg string
 	switch len(argNames) {
 	case 0:
-		return fmt.Errorf("missing positional arguments in '%s'", usage(ctx))
+		msg = fmt.Sprintf("missing positional arguments in '%s'", usage(ctx))
 	case 1:
-		return fmt.Errorf("missing positional argument <%s> in '%s'", argNames[0], usage(ctx))
+		msg = fmt.Sprintf("missing positional argument <%s> in '%s'", argNames[0], usage(ctx))
 	default:
 		args := make([]string, len(argNames))
 		for i, name := range argNames {
 			args[i] = "<" + name + ">"
 		}
-		return fmt.Errorf("missing positional arguments %s in '%s'", strings.Join(args, " "), usage(ctx))
+		msg = fmt.Sprintf("missing positional arguments %s in '%s'", strings.Join(args, " "), usage(ctx))
 	}
+	return newArgumentError(CodeMissingArguments, CategoryArguments, msg, argNames...)
 }
 
 // NumberOfArguments returns nil if the number of positional arguments is
@@ -104,25 +108,34 @@ func MinMaxNumberOfArguments(ctx *cli.Context, minNumber, maxNumber int) error {
 
 // TooFewArguments returns an error with a few arguments were provided message.
 func TooFewArguments(ctx *cli.Context) error {
-	return fmt.Errorf("not enough positional arguments were provided in '%s'", usage(ctx))
+	return newArgumentError(CodeTooFewArguments, CategoryArguments,
+		fmt.Sprintf("not enough positional arguments were provided in '%s'", usage(ctx)))
 }
 
 // TooManyArguments returns an error with a too many arguments were provided
 // message.
 func TooManyArguments(ctx *cli.Context) error {
-	return fmt.Errorf("too many positional arguments were provided in '%s'", usage(ctx))
+	return newArgumentError(CodeTooManyArguments, CategoryArguments,
+		fmt.Sprintf("too many positional arguments were provided in '%s'", usage(ctx)))
 }
 
 // InsecureArgument returns an error with the given argument requiring the
 // --insecure flag.
 func InsecureArgument(_ *cli.Context, name string) error {
-	return fmt.Errorf("positional argument <%s> requires the '--insecure' flag", name)
+	return &UsageError{
+		Code:     CodeInsecureArgument,
+		Category: CategoryInsecure,
+		Flags:    []string{"insecure"},
+		Args:     []string{name},
+		msg:      fmt.Sprintf("positional argument <%s> requires the '--insecure' flag", name),
+	}
 }
 
 // FlagValueInsecure returns an error with the given flag and value requiring
 // the --insecure flag.
 func FlagValueInsecure(_ *cli.Context, flag, value string) error {
-	return fmt.Errorf("flag '--%s %s' requires the '--insecure' flag", flag, value)
+	return newFlagError(CodeFlagValueInsecure, CategoryInsecure,
+		fmt.Sprintf("flag '--%s %s' requires the '--insecure' flag", flag, value), flag, "insecure")
 }
 
 // InvalidFlagValue returns an error with the given value being missing or
@@ -147,31 +160,34 @@ func InvalidFlagValueMsg(_ *cli.Context, flag, value, msg string) error {
 		format = fmt.Sprintf("invalid value '%s' for flag '--%s'", value, flag)
 	}
 
-	if msg == "" {
-		return errors.New(format)
+	if msg != "" {
+		format += "; " + msg
 	}
 
-	return errors.New(format + "; " + msg)
+	return newFlagError(CodeInvalidFlagValue, CategoryInvalid, format, flag)
 }
 
 // IncompatibleFlag returns an error with the flag being incompatible with the
 // given value.
 func IncompatibleFlag(_ *cli.Context, flag, value string) error {
-	return fmt.Errorf("flag '--%s' is incompatible with '%s'", flag, value)
+	return newFlagError(CodeIncompatibleFlag, CategoryIncompatible,
+		fmt.Sprintf("flag '--%s' is incompatible with '%s'", flag, value), flag)
 }
 
 // IncompatibleFlagWithFlag returns an error with the flag being incompatible with the
 // given value.
 func IncompatibleFlagWithFlag(_ *cli.Context, flag, withFlag string) error {
-	return fmt.Errorf("flag '--%s' is incompatible with '--%s'", flag, withFlag)
+	return newFlagError(CodeIncompatibleFlagWithFlag, CategoryIncompatible,
+		fmt.Sprintf("flag '--%s' is incompatible with '--%s'", flag, withFlag), flag, withFlag)
 }
 
 // IncompatibleFlagValue returns an error with the flag being incompatible with the
 // given value.
 func IncompatibleFlagValue(_ *cli.Context, flag, incompatibleWith,
 	incompatibleWithValue string) error {
-	return fmt.Errorf("flag '--%s' is incompatible with flag '--%s %s'",
-		flag, incompatibleWith, incompatibleWithValue)
+	return newFlagError(CodeIncompatibleFlagValue, CategoryIncompatible,
+		fmt.Sprintf("flag '--%s' is incompatible with flag '--%s %s'", flag, incompatibleWith, incompatibleWithValue),
+		flag, incompatibleWith)
 }
 
 // IncompatibleFlagValues returns an error with the flag being incompatible with the
@@ -190,45 +206,50 @@ func IncompatibleFlagValueWithFlagValue(_ *cli.Context, flag, value,
 	format := fmt.Sprintf("flag '--%s %s' is incompatible with flag '--%s %s'",
 		flag, value, withFlag, withValue)
 
-	if options == "" {
-		return errors.New(format)
+	if options != "" {
+		// TODO: check whether double space before options is intended
+		format = fmt.Sprintf("%s\n\n  Option(s): --%s %s", format, withFlag, options)
 	}
 
-	// TODO: check whether double space before options is intended
-	return fmt.Errorf("%s\n\n  Option(s): --%s %s", format, withFlag, options)
+	return newFlagError(CodeIncompatibleFlagValues, CategoryIncompatible, format, flag, withFlag)
 }
 
 // RequiredFlag returns an error with the required flag message.
 func RequiredFlag(ctx *cli.Context, flag string) error {
-	return fmt.Errorf("'%s %s' requires the '--%s' flag", ctx.App.HelpName,
-		ctx.Command.Name, flag)
+	return newFlagError(CodeRequiredFlag, CategoryRequired,
+		fmt.Sprintf("'%s %s' requires the '--%s' flag", ctx.App.HelpName, ctx.Command.Name, flag), flag)
 }
 
 // RequiredWithFlag returns an error with the required flag message with another flag.
 func RequiredWithFlag(_ *cli.Context, flag, required string) error {
-	return fmt.Errorf("flag '--%s' requires the '--%s' flag", flag, required)
+	return newFlagError(CodeRequiredWithFlag, CategoryRequired,
+		fmt.Sprintf("flag '--%s' requires the '--%s' flag", flag, required), flag, required)
 }
 
 // RequiredWithFlagValue returns an error with the required flag message.
 func RequiredWithFlagValue(_ *cli.Context, flag, value, required string) error {
-	return fmt.Errorf("'--%s %s' requires the '--%s' flag", flag, value, required)
+	return newFlagError(CodeRequiredWithFlagValue, CategoryRequired,
+		fmt.Sprintf("'--%s %s' requires the '--%s' flag", flag, value, required), flag, required)
 }
 
 // RequiredWithProvisionerTypeFlag returns an error with the required flag message.
 func RequiredWithProvisionerTypeFlag(_ *cli.Context, provisionerType, required string) error {
-	return fmt.Errorf("provisioner type '%s' requires the '--%s' flag", provisionerType, required)
+	return newFlagError(CodeRequiredWithProvisionerType, CategoryRequired,
+		fmt.Sprintf("provisioner type '%s' requires the '--%s' flag", provisionerType, required), required)
 }
 
 // RequiredInsecureFlag returns an error with the given flag requiring the
 // insecure flag message.
 func RequiredInsecureFlag(_ *cli.Context, flag string) error {
-	return fmt.Errorf("flag '--%s' requires the '--insecure' flag", flag)
+	return newFlagError(CodeRequiredInsecureFlag, CategoryInsecure,
+		fmt.Sprintf("flag '--%s' requires the '--insecure' flag", flag), flag, "insecure")
 }
 
 // RequiredSubtleFlag returns an error with the given flag requiring the
 // subtle flag message..
 func RequiredSubtleFlag(_ *cli.Context, flag string) error {
-	return fmt.Errorf("flag '--%s' requires the '--subtle' flag", flag)
+	return newFlagError(CodeRequiredSubtleFlag, CategoryInsecure,
+		fmt.Sprintf("flag '--%s' requires the '--subtle' flag", flag), flag, "subtle")
 }
 
 // RequiredUnlessInsecureFlag returns an error with the required flag message unless
@@ -240,7 +261,8 @@ func RequiredUnlessInsecureFlag(ctx *cli.Context, flag string) error {
 // RequiredUnlessFlag returns an error with the required flag message unless
 // the specified flag is used.
 func RequiredUnlessFlag(_ *cli.Context, flag, unlessFlag string) error {
-	return fmt.Errorf("flag '--%s' is required unless the '--%s' flag is provided", flag, unlessFlag)
+	return newFlagError(CodeRequiredUnlessFlag, CategoryRequired,
+		fmt.Sprintf("flag '--%s' is required unless the '--%s' flag is provided", flag, unlessFlag), flag, unlessFlag)
 }
 
 // RequiredUnlessSubtleFlag returns an error with the required flag message unless
@@ -255,7 +277,8 @@ func RequiredOrFlag(_ *cli.Context, flags ...string) error {
 	for i, flag := range flags {
 		params[i] = "--" + flag
 	}
-	return fmt.Errorf("one of flag %s is required", strings.Join(params, " or "))
+	return newFlagError(CodeRequiredOrFlag, CategoryRequired,
+		fmt.Sprintf("one of flag %s is required", strings.Join(params, " or ")), flags...)
 }
 
 // RequiredWithOrFlag returns an error with a list of flags at least one of which
@@ -265,31 +288,37 @@ func RequiredWithOrFlag(_ *cli.Context, withFlag string, flags ...string) error
 	for i := 0; i < len(flags); i++ {
 		params[i] = "--" + flags[i]
 	}
-	return fmt.Errorf("one of flag %s is required with flag --%s", strings.Join(params, " or "), withFlag)
+	return newFlagError(CodeRequiredWithOrFlag, CategoryRequired,
+		fmt.Sprintf("one of flag %s is required with flag --%s", strings.Join(params, " or "), withFlag),
+		append(append([]string{}, flags...), withFlag)...)
 }
 
 // MinSizeFlag returns an error with a greater or equal message message for
 // the given flag and size.
 func MinSizeFlag(_ *cli.Context, flag, size string) error {
-	return fmt.Errorf("flag '--%s' must be greater than or equal to %s", flag, size)
+	return newFlagError(CodeMinSizeFlag, CategoryInvalid,
+		fmt.Sprintf("flag '--%s' must be greater than or equal to %s", flag, size), flag)
 }
 
 // MinSizeInsecureFlag returns an error with a requiring --insecure flag
 // message with the given flag an size.
 func MinSizeInsecureFlag(_ *cli.Context, flag, size string) error {
-	return fmt.Errorf("flag '--%s' requires at least %s unless '--insecure' flag is provided", flag, size)
+	return newFlagError(CodeMinSizeInsecureFlag, CategoryInsecure,
+		fmt.Sprintf("flag '--%s' requires at least %s unless '--insecure' flag is provided", flag, size), flag, "insecure")
 }
 
 // MutuallyExclusiveFlags returns an error with mutually exclusive message for
 // the given flags.
 func MutuallyExclusiveFlags(_ *cli.Context, flag1, flag2 string) error {
-	return fmt.Errorf("flag '--%s' and flag '--%s' are mutually exclusive", flag1, flag2)
+	return newFlagError(CodeMutuallyExclusiveFlags, CategoryIncompatible,
+		fmt.Sprintf("flag '--%s' and flag '--%s' are mutually exclusive", flag1, flag2), flag1, flag2)
 }
 
 // UnsupportedFlag returns an error with a message saying that the given flag is
 // not yet supported.
 func UnsupportedFlag(_ *cli.Context, flag string) error {
-	return fmt.Errorf("flag '--%s' is not yet supported", flag)
+	return newFlagError(CodeUnsupportedFlag, CategoryUnsupported,
+		fmt.Sprintf("flag '--%s' is not yet supported", flag), flag)
 }
 
 // usage returns the command usage text if set or a default usage string.
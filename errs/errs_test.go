@@ -3,6 +3,7 @@ package errs
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"strconv"
@@ -307,6 +308,62 @@ func TestUnsupportedFlag(t *testing.T) {
 	assert.EqualError(t, UnsupportedFlag(ctx, "f1"), exp)
 }
 
+func TestUsageError(t *testing.T) {
+	ctx := newTestCLI(t, "arg1")
+
+	tests := []struct {
+		name     string
+		err      error
+		code     Code
+		category Category
+		flags    []string
+		args     []string
+	}{
+		{"InsecureCommand", InsecureCommand(ctx), CodeInsecureCommand, CategoryInsecure, []string{"insecure"}, nil},
+		{"EqualArguments", EqualArguments(ctx, "a1", "a2"), CodeEqualArguments, CategoryArguments, nil, []string{"a1", "a2"}},
+		{"MissingArguments", MissingArguments(ctx, "a1"), CodeMissingArguments, CategoryArguments, nil, []string{"a1"}},
+		{"TooFewArguments", NumberOfArguments(ctx, 2), CodeTooFewArguments, CategoryArguments, nil, nil},
+		{"TooManyArguments", MinMaxNumberOfArguments(ctx, 0, 0), CodeTooManyArguments, CategoryArguments, nil, nil},
+		{"InsecureArgument", InsecureArgument(ctx, "a1"), CodeInsecureArgument, CategoryInsecure, []string{"insecure"}, []string{"a1"}},
+		{"FlagValueInsecure", FlagValueInsecure(ctx, "f1", "v1"), CodeFlagValueInsecure, CategoryInsecure, []string{"f1", "insecure"}, nil},
+		{"InvalidFlagValue", InvalidFlagValue(ctx, "f1", "v1", "'v2'"), CodeInvalidFlagValue, CategoryInvalid, []string{"f1"}, nil},
+		{"IncompatibleFlag", IncompatibleFlag(ctx, "f1", "v1"), CodeIncompatibleFlag, CategoryIncompatible, []string{"f1"}, nil},
+		{"IncompatibleFlagWithFlag", IncompatibleFlagWithFlag(ctx, "f1", "f2"), CodeIncompatibleFlagWithFlag, CategoryIncompatible, []string{"f1", "f2"}, nil},
+		{"IncompatibleFlagValue", IncompatibleFlagValue(ctx, "f1", "f2", "v2"), CodeIncompatibleFlagValue, CategoryIncompatible, []string{"f1", "f2"}, nil},
+		{"IncompatibleFlagValues", IncompatibleFlagValues(ctx, "f1", "v1", "f2", "v2"), CodeIncompatibleFlagValues, CategoryIncompatible, []string{"f1", "f2"}, nil},
+		{"RequiredFlag", RequiredFlag(ctx, "f1"), CodeRequiredFlag, CategoryRequired, []string{"f1"}, nil},
+		{"RequiredWithFlag", RequiredWithFlag(ctx, "f1", "f2"), CodeRequiredWithFlag, CategoryRequired, []string{"f1", "f2"}, nil},
+		{"RequiredWithFlagValue", RequiredWithFlagValue(ctx, "f1", "v1", "f2"), CodeRequiredWithFlagValue, CategoryRequired, []string{"f1", "f2"}, nil},
+		{"RequiredWithProvisionerTypeFlag", RequiredWithProvisionerTypeFlag(ctx, "p1", "f1"), CodeRequiredWithProvisionerType, CategoryRequired, []string{"f1"}, nil},
+		{"RequiredInsecureFlag", RequiredInsecureFlag(ctx, "f1"), CodeRequiredInsecureFlag, CategoryInsecure, []string{"f1", "insecure"}, nil},
+		{"RequiredSubtleFlag", RequiredSubtleFlag(ctx, "f1"), CodeRequiredSubtleFlag, CategoryInsecure, []string{"f1", "subtle"}, nil},
+		{"RequiredUnlessInsecureFlag", RequiredUnlessInsecureFlag(ctx, "f1"), CodeRequiredUnlessFlag, CategoryRequired, []string{"f1", "insecure"}, nil},
+		{"RequiredOrFlag", RequiredOrFlag(ctx, "f1", "f2"), CodeRequiredOrFlag, CategoryRequired, []string{"f1", "f2"}, nil},
+		{"RequiredWithOrFlag", RequiredWithOrFlag(ctx, "f3", "f1", "f2"), CodeRequiredWithOrFlag, CategoryRequired, []string{"f1", "f2", "f3"}, nil},
+		{"MinSizeFlag", MinSizeFlag(ctx, "f1", "10"), CodeMinSizeFlag, CategoryInvalid, []string{"f1"}, nil},
+		{"MinSizeInsecureFlag", MinSizeInsecureFlag(ctx, "f1", "10"), CodeMinSizeInsecureFlag, CategoryInsecure, []string{"f1", "insecure"}, nil},
+		{"MutuallyExclusiveFlags", MutuallyExclusiveFlags(ctx, "f1", "f2"), CodeMutuallyExclusiveFlags, CategoryIncompatible, []string{"f1", "f2"}, nil},
+		{"UnsupportedFlag", UnsupportedFlag(ctx, "f1"), CodeUnsupportedFlag, CategoryUnsupported, []string{"f1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The error can be wrapped
+			err := fmt.Errorf("wrapped: %w", tt.err)
+
+			var ue *UsageError
+			require.True(t, errors.As(err, &ue))
+			assert.Equal(t, tt.code, ue.Code)
+			assert.Equal(t, tt.category, ue.Category)
+			assert.Equal(t, tt.flags, ue.Flags)
+			assert.Equal(t, tt.args, ue.Args)
+			assert.Equal(t, tt.err.Error(), ue.Error())
+
+			assert.True(t, errors.Is(err, &UsageError{Code: tt.code}))
+			assert.False(t, errors.Is(err, &UsageError{Code: "other"}))
+		})
+	}
+}
+
 func TestFileError(t *testing.T) {
 	tests := []struct {
 		err      error
@@ -0,0 +1,97 @@
+package errs
+
+// Category is the category of a UsageError.
+type Category string
+
+const (
+	// CategoryArguments is the category of the errors in the positional
+	// arguments of a command.
+	CategoryArguments Category = "arguments"
+	// CategoryRequired is the category of the errors for missing flags.
+	CategoryRequired Category = "required"
+	// CategoryInvalid is the category of the errors for missing or invalid
+	// flag values.
+	CategoryInvalid Category = "invalid"
+	// CategoryIncompatible is the category of the errors for flags that
+	// cannot be used together.
+	CategoryIncompatible Category = "incompatible"
+	// CategoryInsecure is the category of the errors for commands, flags or
+	// arguments that require the --insecure or --subtle flags.
+	CategoryInsecure Category = "insecure"
+	// CategoryUnsupported is the category of the errors for flags that are
+	// not supported.
+	CategoryUnsupported Category = "unsupported"
+)
+
+// Code is a stable identifier of a UsageError. Codes do not change if the
+// message of the error changes, so they can be used by programs and scripts.
+type Code string
+
+// Codes of the errors returned by the helpers of this package.
+const (
+	CodeInsecureCommand             Code = "insecure-command"
+	CodeEqualArguments              Code = "equal-arguments"
+	CodeMissingArguments            Code = "missing-arguments"
+	CodeTooFewArguments             Code = "too-few-arguments"
+	CodeTooManyArguments            Code = "too-many-arguments"
+	CodeInsecureArgument            Code = "insecure-argument"
+	CodeFlagValueInsecure           Code = "flag-value-insecure"
+	CodeInvalidFlagValue            Code = "invalid-flag-value"
+	CodeIncompatibleFlag            Code = "incompatible-flag"
+	CodeIncompatibleFlagWithFlag    Code = "incompatible-flag-with-flag"
+	CodeIncompatibleFlagValue       Code = "incompatible-flag-value"
+	CodeIncompatibleFlagValues      Code = "incompatible-flag-values"
+	CodeRequiredFlag                Code = "required-flag"
+	CodeRequiredWithFlag            Code = "required-with-flag"
+	CodeRequiredWithFlagValue       Code = "required-with-flag-value"
+	CodeRequiredWithProvisionerType Code = "required-with-provisioner-type"
+	CodeRequiredInsecureFlag        Code = "required-insecure-flag"
+	CodeRequiredSubtleFlag          Code = "required-subtle-flag"
+	CodeRequiredUnlessFlag          Code = "required-unless-flag"
+	CodeRequiredOrFlag              Code = "required-or-flag"
+	CodeRequiredWithOrFlag          Code = "required-with-or-flag"
+	CodeMinSizeFlag                 Code = "min-size-flag"
+	CodeMinSizeInsecureFlag         Code = "min-size-insecure-flag"
+	CodeMutuallyExclusiveFlags      Code = "mutually-exclusive-flags"
+	CodeUnsupportedFlag             Code = "unsupported-flag"
+)
+
+// UsageError is the error returned by the helpers of this package for
+// invalid uses of a command, like a missing flag or too many arguments. It can
+// be retrieved with errors.As:
+//
+//	var ue *errs.UsageError
+//	if errors.As(err, &ue) && ue.Code == errs.CodeRequiredFlag {
+//		// ...
+//	}
+type UsageError struct {
+	Code     Code
+	Category Category
+	// Flags are the names of the flags involved, without the leading dashes.
+	Flags []string
+	// Args are the names of the positional arguments involved.
+	Args []string
+	msg  string
+}
+
+// Error implements the error interface and returns the same message as the
+// helper that created the error.
+func (e *UsageError) Error() string {
+	return e.msg
+}
+
+// Is returns true if target is a *UsageError with the same code, so
+// errors.Is(err, &UsageError{Code: CodeRequiredFlag}) can be used to check the
+// kind of error.
+func (e *UsageError) Is(target error) bool {
+	t, ok := target.(*UsageError)
+	return ok && t.Code == e.Code
+}
+
+func newFlagError(code Code, category Category, msg string, flags ...string) error {
+	return &UsageError{Code: code, Category: category, Flags: flags, msg: msg}
+}
+
+func newArgumentError(code Code, category Category, msg string, args ...string) error {
+	return &UsageError{Code: code, Category: category, Args: args, msg: msg}
+}
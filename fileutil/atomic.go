@@ -0,0 +1,124 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// filename, syncs it and renames it to filename, so a crash or a full disk
+// never leaves a partially written file. If the file exists, its mode and
+// ownership are preserved, and if it is a symbolic link, the target of the
+// link is replaced.
+//
+// An existing file is written in place, truncating it, if the temporary file
+// cannot be created because the directory is not writable, or if the ownership
+// of the file cannot be preserved, for example in a group-writable file owned
+// by another user. These writes are not atomic.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	if f := getFS(); !fsys.IsOS(f) {
+		if st, err := f.Stat(filename); err == nil {
+			if st.IsDir() {
+				return ErrIsDir
+			}
+			perm = st.Mode().Perm()
+		}
+		return FileError(fsys.WriteFileAtomic(f, filename, data, perm), filename)
+	}
+
+	var st os.FileInfo
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+		if st, err = os.Stat(filename); err != nil {
+			return FileError(err, filename)
+		}
+		if st.IsDir() {
+			return ErrIsDir
+		}
+		perm = st.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return FileError(err, filename)
+	}
+
+	tmp, err := writeTemp(filename, data, perm)
+	if err != nil {
+		if st != nil && errors.Is(err, os.ErrPermission) {
+			return writeInPlace(filename, data)
+		}
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if st != nil {
+		if err := chown(tmp, st); err != nil {
+			return writeInPlace(filename, data)
+		}
+	}
+	return rename(tmp, filename)
+}
+
+// writeInPlace truncates the existing file and writes data to it, keeping its
+// mode and ownership.
+func writeInPlace(filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return FileError(err, filename)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return FileError(err, filename)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return FileError(err, filename)
+	}
+	return FileError(f.Close(), filename)
+}
+
+// writeTemp writes data to a new temporary file, with the given permissions,
+// in the same directory as filename and syncs it. It returns the name of the
+// temporary file.
+func writeTemp(filename string, data []byte, perm os.FileMode) (string, error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating temporary file for %s", filename)
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", FileError(err, tmp)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", FileError(err, tmp)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", FileError(err, tmp)
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return "", FileError(err, tmp)
+	}
+	return tmp, nil
+}
+
+// rename renames the temporary file to filename and syncs the directory.
+func rename(tmp, filename string) error {
+	if err := os.Rename(tmp, filename); err != nil {
+		return FileError(err, filename)
+	}
+	dir := filepath.Dir(filename)
+	return syncDir(dir)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+func chown(string, os.FileInfo) error { return nil }
+
+func syncDir(string) error { return nil }
@@ -0,0 +1,119 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	readDir := func(t *testing.T) []string {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	t.Run("new", func(t *testing.T) {
+		fn := filepath.Join(dir, "new")
+		require.NoError(t, writeFileAtomic(fn, []byte("new"), 0640))
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(b))
+		if runtime.GOOS != "windows" {
+			st, err := os.Stat(fn)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0640), st.Mode().Perm())
+		}
+	})
+
+	t.Run("preserve mode", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("file modes are not supported on windows")
+		}
+		fn := filepath.Join(dir, "existing")
+		require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+		require.NoError(t, os.Chmod(fn, 0604))
+		require.NoError(t, writeFileAtomic(fn, []byte("new"), 0666))
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(b))
+		st, err := os.Stat(fn)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0604), st.Mode().Perm())
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symbolic links require privileges on windows")
+		}
+		target := filepath.Join(dir, "target")
+		link := filepath.Join(dir, "link")
+		require.NoError(t, os.WriteFile(target, []byte("old"), 0600))
+		require.NoError(t, os.Symlink(target, link))
+		require.NoError(t, writeFileAtomic(link, []byte("new"), 0600))
+		st, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.Equal(t, os.ModeSymlink, st.Mode()&os.ModeSymlink)
+		b, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(b))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		sub := filepath.Join(dir, "sub")
+		require.NoError(t, os.Mkdir(sub, 0700))
+		assert.Equal(t, ErrIsDir, writeFileAtomic(sub, []byte("new"), 0600))
+	})
+
+	t.Run("read-only directory", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Getuid() == 0 {
+			t.Skip("directory permissions are not enforced")
+		}
+		ro := filepath.Join(dir, "ro")
+		require.NoError(t, os.Mkdir(ro, 0700))
+		fn := filepath.Join(ro, "known_hosts")
+		require.NoError(t, os.WriteFile(fn, []byte("old content"), 0644))
+		require.NoError(t, os.Chmod(ro, 0500))
+		t.Cleanup(func() { os.Chmod(ro, 0700) })
+
+		require.NoError(t, writeFileAtomic(fn, []byte("new"), 0600))
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(b))
+		st, err := os.Stat(fn)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), st.Mode().Perm())
+
+		// New files cannot be created
+		assert.Error(t, writeFileAtomic(filepath.Join(ro, "new"), []byte("new"), 0600))
+	})
+
+	t.Run("in place", func(t *testing.T) {
+		fn := filepath.Join(dir, "in-place")
+		require.NoError(t, os.WriteFile(fn, []byte("old content"), 0600))
+		require.NoError(t, writeInPlace(fn, []byte("new")))
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(b))
+		assert.Error(t, writeInPlace(filepath.Join(dir, "missing"), []byte("new")))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		assert.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "file"), []byte("new"), 0600))
+	})
+
+	// No temporary files are left behind
+	for _, name := range readDir(t) {
+		assert.NotContains(t, name, ".tmp")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown sets the owner and group of the given file to the ones in st, if they
+// are different from the current ones.
+func chown(name string, st os.FileInfo) error {
+	want, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	cur, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if got, ok := cur.Sys().(*syscall.Stat_t); ok && got.Uid == want.Uid && got.Gid == want.Gid {
+		return nil
+	}
+	return os.Chown(name, int(want.Uid), int(want.Gid))
+}
+
+// syncDir syncs the given directory, so a rename of one of its entries is
+// persisted.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return FileError(err, dir)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return FileError(err, dir)
+	}
+	return nil
+}
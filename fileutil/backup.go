@@ -0,0 +1,387 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+	"github.com/smallstep/cli-utils/step"
+	"github.com/smallstep/cli-utils/ui"
+)
+
+// backupTimeFormat is the format of the timestamp used in the backup ids.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// maxBackups is the maximum number of entries in the backup manifest. The
+// oldest entries and their copies are removed when a new backup exceeds it.
+var maxBackups = 100
+
+var (
+	backupMu      sync.Mutex
+	backupEnabled = true
+)
+
+// ErrBackupModified is the error returned by Restore if a file has been
+// modified after the change recorded in the backup manifest.
+var ErrBackupModified = errors.New("file has been modified")
+
+// Backup is an entry in the backup manifest. Each entry describes a
+// modification of a file made by the fileutil write functions.
+type Backup struct {
+	// ID is the unique identifier of the modification.
+	ID string `json:"id"`
+	// Time is the time of the modification.
+	Time time.Time `json:"time"`
+	// Command is the command that modified the file.
+	Command string `json:"command,omitempty"`
+	// Filename is the absolute path of the modified file.
+	Filename string `json:"filename"`
+	// BackupFile is the path of the copy of the previous content of the file,
+	// relative to the backup directory. It is empty if the file did not exist.
+	BackupFile string `json:"backupFile,omitempty"`
+	// Perm is the previous mode of the file.
+	Perm os.FileMode `json:"perm,omitempty"`
+	// Hash is the hex encoded SHA-256 of the content written, used to detect
+	// changes made after the modification.
+	Hash string `json:"hash,omitempty"`
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetBackup enables or disables the backups of the configuration files edited
+// by the fileutil functions. Backups are enabled by default.
+func SetBackup(enabled bool) {
+	backupMu.Lock()
+	backupEnabled = enabled
+	backupMu.Unlock()
+}
+
+// BackupDir returns the directory where the backups and the backup manifest
+// are stored.
+func BackupDir() string {
+	return filepath.Join(step.StatePath(), "backups")
+}
+
+// backupManifest returns the location of the backup manifest. The manifest
+// contains a JSON encoded Backup per line.
+func backupManifest() string {
+	return filepath.Join(BackupDir(), "manifest.jsonl")
+}
+
+// backupManifestLock returns the location of the file locked while the backup
+// manifest is read or modified.
+func backupManifestLock() string {
+	return filepath.Join(BackupDir(), "manifest.lock")
+}
+
+// lockBackups acquires an advisory lock on the backup manifest, so multiple
+// processes do not modify it at the same time, and returns the function that
+// releases it. The lock is only used with the OS file system.
+func lockBackups(mode LockMode) (func(), error) {
+	if !fsys.IsOS(getFS()) {
+		return func() {}, nil
+	}
+	dir := BackupDir()
+	if err := getFS().MkdirAll(dir, 0700); err != nil {
+		return nil, FileError(err, dir)
+	}
+	f, err := OpenLocked(backupManifestLock(), os.O_RDWR|os.O_CREATE, 0600, mode)
+	if err != nil {
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// backupFile stores a copy of the current content of the given file in the
+// backup directory and adds an entry to the manifest with the hash of data, the
+// content that is going to be written. If the file does not exist, the entry is
+// added so a restore will remove it.
+func backupFile(filename string, data []byte) error {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	if !backupEnabled {
+		return nil
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return errors.Wrapf(err, "error getting absolute path of %s", filename)
+	}
+	f := getFS()
+	if fsys.IsOS(f) {
+		if target, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = target
+		}
+	}
+
+	now := time.Now().UTC()
+	b := Backup{
+		ID:       now.Format(backupTimeFormat) + "-" + filepath.Base(abs),
+		Time:     now,
+		Command:  command.Name(),
+		Filename: abs,
+		Hash:     hashContent(data),
+	}
+
+	var old []byte
+	st, err := f.Stat(abs)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return FileError(err, abs)
+	case st.IsDir():
+		return ErrIsDir
+	default:
+		if old, err = f.ReadFile(abs); err != nil {
+			return FileError(err, abs)
+		}
+		b.BackupFile = b.ID
+		b.Perm = st.Mode().Perm()
+	}
+
+	unlock, err := lockBackups(LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := BackupDir()
+	if err := f.MkdirAll(dir, 0700); err != nil {
+		return FileError(err, dir)
+	}
+	if b.BackupFile != "" {
+		if err := writeFileAtomic(filepath.Join(dir, b.BackupFile), old, 0600); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling backup")
+	}
+	mf := backupManifest()
+	w, err := f.OpenFile(mf, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return FileError(err, mf)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		w.Close()
+		return FileError(err, mf)
+	}
+	if err := w.Close(); err != nil {
+		return FileError(err, mf)
+	}
+	return pruneBackups()
+}
+
+// pruneBackups removes the oldest entries of the manifest, and their copies,
+// if there are more than maxBackups.
+func pruneBackups() error {
+	backups, err := readBackups()
+	if err != nil || len(backups) <= maxBackups {
+		return err
+	}
+	n := len(backups) - maxBackups
+	if err := writeBackups(backups[n:]); err != nil {
+		return err
+	}
+	for _, b := range backups[:n] {
+		if b.BackupFile != "" {
+			getFS().Remove(filepath.Join(BackupDir(), b.BackupFile))
+		}
+	}
+	return nil
+}
+
+// Backups returns the entries of the backup manifest, from the oldest to the
+// newest modification.
+func Backups() ([]Backup, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	unlock, err := lockBackups(LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readBackups()
+}
+
+func readBackups() ([]Backup, error) {
+	mf := backupManifest()
+	data, err := getFS().ReadFile(mf)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, FileError(err, mf)
+	}
+
+	var backups []Backup
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var b Backup
+		if err := json.Unmarshal([]byte(line), &b); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", mf)
+		}
+		backups = append(backups, b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", mf)
+	}
+	return backups, nil
+}
+
+// Restore rolls back the last n modifications in the backup manifest, from
+// the newest to the oldest. A modified file is restored to its previous
+// content, and a created file is removed. It returns the entries restored,
+// that are removed from the manifest. In dry-run mode, the changes are only
+// recorded and the manifest is not modified.
+//
+// If a file has been modified after the recorded change, the user is asked
+// before restoring it; without a terminal, it fails with ErrBackupModified
+// unless the force flag is used.
+func Restore(n int) ([]Backup, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	unlock, err := lockBackups(LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	backups, err := readBackups()
+	if err != nil {
+		return nil, err
+	}
+	if n > len(backups) {
+		n = len(backups)
+	}
+
+	dir := BackupDir()
+	keep, restore := backups[:len(backups)-n], backups[len(backups)-n:]
+	var restored []Backup
+	for i := len(restore) - 1; i >= 0; i-- {
+		b := restore[i]
+		if err := restoreBackup(dir, b); err != nil {
+			return restored, errors.Wrapf(err, "error restoring %s", b.Filename)
+		}
+		restored = append(restored, b)
+		if IsDryRun() {
+			continue
+		}
+		// Update the manifest after each restore, so a failure does not
+		// restore the same modification twice.
+		if err := writeBackups(append(keep, restore[:i]...)); err != nil {
+			return restored, err
+		}
+		if b.BackupFile != "" {
+			getFS().Remove(filepath.Join(dir, b.BackupFile))
+		}
+	}
+	return restored, nil
+}
+
+func restoreBackup(dir string, b Backup) error {
+	var data []byte
+	if b.BackupFile != "" {
+		fn := filepath.Join(dir, b.BackupFile)
+		var err error
+		if data, err = getFS().ReadFile(fn); err != nil {
+			return FileError(err, fn)
+		}
+	}
+
+	current, err := readFile(b.Filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return FileError(err, b.Filename)
+		}
+		current = nil
+	}
+	if b.Hash != "" && (current == nil || hashContent(current) != b.Hash) {
+		if err := confirmRestore(b.Filename); err != nil {
+			return err
+		}
+	}
+
+	if r := getRecorder(); r != nil {
+		if data == nil {
+			data = []byte{}
+		}
+		return r.record(Change{
+			Filename: b.Filename,
+			Old:      current,
+			New:      data,
+			Perm:     b.Perm,
+		})
+	}
+
+	if b.BackupFile == "" {
+		if err := getFS().Remove(b.Filename); err != nil && !os.IsNotExist(err) {
+			return FileError(err, b.Filename)
+		}
+		return nil
+	}
+	if err := writeFileAtomic(b.Filename, data, b.Perm); err != nil {
+		return err
+	}
+	// writeFileAtomic preserves the mode of an existing file.
+	return FileError(getFS().Chmod(b.Filename, b.Perm), b.Filename)
+}
+
+// confirmRestore asks the user to restore a file that has been modified after
+// the change recorded in the backup manifest. It returns ErrBackupModified if
+// the user declines or if it cannot prompt. The file is restored without a
+// prompt using the force flag or in dry-run mode.
+func confirmRestore(filename string) error {
+	if command.IsForce() || IsDryRun() {
+		return nil
+	}
+	if !ui.IsInteractive() {
+		return errors.Wrapf(ErrBackupModified, "the file has been modified after the backup; use the '--force' flag to restore it")
+	}
+	str, err := ui.Prompt(fmt.Sprintf("%s has been modified after the backup, would you like to discard the changes [y/n]", filename), ui.WithValidateYesNo())
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errors.Wrap(ErrBackupModified, "the file has been modified after the backup")
+	}
+}
+
+func writeBackups(backups []Backup) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, b := range backups {
+		if err := enc.Encode(b); err != nil {
+			return errors.Wrap(err, "error marshaling backup")
+		}
+	}
+	return writeFileAtomic(backupManifest(), buf.Bytes(), 0600)
+}
@@ -0,0 +1,182 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/cli-utils/ui"
+)
+
+func TestRestore(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	created := filepath.Join(dir, "created")
+	require.NoError(t, os.WriteFile(config, []byte("Host foo\n"), 0644))
+
+	require.NoError(t, WriteSnippet(config, []byte("Include step"), 0644))
+	require.NoError(t, PrependLine(config, []byte("Host *"), 0644))
+	require.NoError(t, AppendNewLine(created, []byte("created"), 0644))
+
+	backups, err := Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	assert.Equal(t, config, backups[0].Filename)
+	assert.NotEmpty(t, backups[0].BackupFile)
+	assert.Equal(t, created, backups[2].Filename)
+	assert.Empty(t, backups[2].BackupFile)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0644), backups[0].Perm)
+	}
+
+	// Restore created file
+	restored, err := Restore(1)
+	require.NoError(t, err)
+	assert.Equal(t, backups[2:], restored)
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+
+	// Dry-run does not modify the files or the manifest
+	var buf bytes.Buffer
+	SetRecorder(NewRecorder(&buf))
+	restored, err = Restore(5)
+	SetRecorder(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Backup{backups[1], backups[0]}, restored)
+	assert.Contains(t, buf.String(), "-Host *\n")
+	b, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "Host *")
+
+	// Restore the rest
+	restored, err = Restore(5)
+	require.NoError(t, err)
+	assert.Equal(t, []Backup{backups[1], backups[0]}, restored)
+	b, err = os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Equal(t, "Host foo\n", string(b))
+
+	backups, err = Backups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+	entries, err := os.ReadDir(BackupDir())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "manifest.jsonl", entries[0].Name())
+	assert.Equal(t, "manifest.lock", entries[1].Name())
+
+	restored, err = Restore(1)
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
+
+func TestSetBackup(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+	SetBackup(false)
+	t.Cleanup(func() { SetBackup(true) })
+
+	require.NoError(t, WriteSnippet(filepath.Join(t.TempDir(), "file"), []byte("data"), 0644))
+	backups, err := Backups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestBackup_skipped(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+
+	dir := t.TempDir()
+	key := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(key, []byte("old key"), 0600))
+
+	// Files written with WriteFile, like keys, are not backed up.
+	require.NoError(t, WriteFile(key, []byte("new key"), 0600, WithOverwritePolicy(OverwriteAlways)))
+	require.NoError(t, WriteFile(filepath.Join(dir, "cert"), []byte("cert"), 0644))
+
+	backups, err := Backups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestBackup_ownerOnly(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+
+	// Configuration files only readable by the owner, like ~/.ssh/config, are
+	// backed up too.
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	created := filepath.Join(dir, "created")
+	require.NoError(t, os.WriteFile(config, []byte("Host foo\n"), 0600))
+	require.NoError(t, EditFile(config, 0600, AppendLines("Host bar")))
+	require.NoError(t, EditFile(created, 0600, AppendLines("Host baz")))
+
+	backups, err := Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), backups[0].Perm)
+	}
+	assert.Empty(t, backups[1].BackupFile)
+
+	_, err = Restore(2)
+	require.NoError(t, err)
+	b, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Equal(t, "Host foo\n", string(b))
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackup_maxBackups(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+	old := maxBackups
+	t.Cleanup(func() { maxBackups = old })
+	maxBackups = 2
+
+	fn := filepath.Join(t.TempDir(), "config")
+	for _, s := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, AppendNewLine(fn, []byte(s+"\n"), 0644))
+	}
+
+	backups, err := Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	entries, err := os.ReadDir(BackupDir())
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	restored, err := Restore(5)
+	require.NoError(t, err)
+	assert.Len(t, restored, 2)
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(b))
+}
+
+func TestRestore_modified(t *testing.T) {
+	require.NoError(t, os.RemoveAll(BackupDir()))
+	ui.SetNoPrompt(true)
+	t.Cleanup(func() { ui.SetNoPrompt(false) })
+
+	fn := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(fn, []byte("Host foo\n"), 0644))
+	require.NoError(t, AppendNewLine(fn, []byte("Host bar\n"), 0644))
+	require.NoError(t, os.WriteFile(fn, []byte("Host baz\n"), 0644))
+
+	restored, err := Restore(1)
+	assert.ErrorIs(t, err, ErrBackupModified)
+	assert.Empty(t, restored)
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "Host baz\n", string(b))
+
+	backups, err := Backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
@@ -0,0 +1,224 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around the changes in a
+// unified diff.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// splitLines splits b in lines, keeping the line feed at the end of each line.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script that transforms a into b. It uses the
+// linear space variant of the Myers O(ND) algorithm, so large files with few
+// changes, like a known_hosts file, are compared fast and with little memory.
+func diffLines(a, b []string) []diffOp {
+	d := &differ{a: a, b: b, ops: make([]diffOp, 0, max(len(a), len(b)))}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type differ struct {
+	a, b []string
+	ops  []diffOp
+}
+
+// compare appends the edit script that transforms a[aStart:aEnd] into
+// b[bStart:bEnd].
+func (d *differ) compare(aStart, aEnd, bStart, bEnd int) {
+	// Common prefix
+	prefix := aStart
+	for aStart < aEnd && bStart < bEnd && d.a[aStart] == d.b[bStart] {
+		aStart++
+		bStart++
+	}
+	d.equal(prefix, aStart)
+
+	// Common suffix, appended after the changes.
+	suffix := aEnd
+	for aStart < aEnd && bStart < bEnd && d.a[aEnd-1] == d.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch {
+	case aStart == aEnd:
+		for _, l := range d.b[bStart:bEnd] {
+			d.ops = append(d.ops, diffOp{'+', l})
+		}
+	case bStart == bEnd:
+		for _, l := range d.a[aStart:aEnd] {
+			d.ops = append(d.ops, diffOp{'-', l})
+		}
+	default:
+		// Without a common prefix and suffix, there are at least two edits,
+		// and each half of the split has fewer edits than the whole.
+		x, y, u, v := d.middleSnake(aStart, aEnd, bStart, bEnd)
+		d.compare(aStart, x, bStart, y)
+		d.equal(x, u)
+		d.compare(u, aEnd, v, bEnd)
+	}
+
+	d.equal(aEnd, suffix)
+}
+
+// equal appends the lines a[start:end] as unchanged.
+func (d *differ) equal(start, end int) {
+	for _, l := range d.a[start:end] {
+		d.ops = append(d.ops, diffOp{' ', l})
+	}
+}
+
+// middleSnake returns the start, (x, y), and the end, (u, v), of the middle
+// snake of a shortest edit script between a[aStart:aEnd] and b[bStart:bEnd],
+// searching forward from the start and backward from the end at the same
+// time. The positions are indexes in a and b.
+func (d *differ) middleSnake(aStart, aEnd, bStart, bEnd int) (x, y, u, v int) {
+	n, m := aEnd-aStart, bEnd-bStart
+	a, b := d.a[aStart:aEnd], d.b[bStart:bEnd]
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// vf and vb store the furthest x reached on each diagonal k, forward and
+	// backward. The backward x and y are distances from the end.
+	off := maxD + 1
+	vf := make([]int, 2*off+1)
+	vb := make([]int, 2*off+1)
+	for e := 0; e <= maxD; e++ {
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if odd && k >= delta-(e-1) && k <= delta+(e-1) && x+vb[off+delta-k] >= n {
+				return aStart + x0, bStart + y0, aStart + x, bStart + y
+			}
+		}
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if !odd && k >= delta-e && k <= delta+e && x+vf[off+delta-k] >= n {
+				return aStart + n - x, bStart + m - y, aStart + n - x0, bStart + m - y0
+			}
+		}
+	}
+	// Not reached, the paths always overlap before maxD.
+	return aStart, bStart, aStart, bStart
+}
+
+// UnifiedDiff returns the unified diff between the old and new contents of the
+// given file. It returns an empty string if there are no changes. A nil old
+// value represents a file that does not exist.
+func UnifiedDiff(filename string, oldData, newData []byte) string {
+	ops := diffLines(splitLines(oldData), splitLines(newData))
+
+	// Find the ranges of operations, with their context, that are part of
+	// each hunk.
+	type hunk struct{ start, end int }
+	var hunks []hunk
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		start, end := max(0, i-diffContext), min(len(ops), i+diffContext+1)
+		if n := len(hunks); n > 0 && start <= hunks[n-1].end {
+			hunks[n-1].end = end
+		} else {
+			hunks = append(hunks, hunk{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if oldData == nil {
+		buf.WriteString("--- /dev/null\n")
+	} else {
+		fmt.Fprintf(&buf, "--- %s\n", filename)
+	}
+	fmt.Fprintf(&buf, "+++ %s\n", filename)
+
+	// Line numbers of the first operation of the hunk
+	var oldLine, newLine, pos int
+	for _, h := range hunks {
+		for ; pos < h.start; pos++ {
+			oldLine, newLine = advance(ops[pos], oldLine, newLine)
+		}
+		var oldCount, newCount int
+		for _, op := range ops[h.start:h.end] {
+			oldCount, newCount = advance(op, oldCount, newCount)
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, op := range ops[h.start:h.end] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(strings.TrimSuffix(op.line, "\n"))
+			buf.WriteByte('\n')
+			if !strings.HasSuffix(op.line, "\n") {
+				buf.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return buf.String()
+}
+
+func advance(op diffOp, oldLine, newLine int) (int, int) {
+	switch op.kind {
+	case '-':
+		return oldLine + 1, newLine
+	case '+':
+		return oldLine, newLine + 1
+	default:
+		return oldLine + 1, newLine + 1
+	}
+}
+
+// hunkRange formats the range of a hunk, start is zero based.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,95 @@
+package fileutil
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldData []byte
+		newData []byte
+		want    string
+	}{
+		{"equal", []byte("a\nb\n"), []byte("a\nb\n"), ""},
+		{"new file", nil, []byte("a\nb\n"), "--- /dev/null\n+++ config\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"empty file", []byte{}, []byte("a\n"), "--- config\n+++ config\n@@ -0,0 +1 @@\n+a\n"},
+		{"remove all", []byte("a\n"), []byte{}, "--- config\n+++ config\n@@ -1 +0,0 @@\n-a\n"},
+		{"change", []byte("a\nb\nc\n"), []byte("a\nB\nc\n"), "--- config\n+++ config\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"no newline", []byte("a\nb"), []byte("a\nb\n"), "--- config\n+++ config\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"hunks",
+			[]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"),
+			[]byte("0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n"),
+			"--- config\n+++ config\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, UnifiedDiff("config", tt.oldData, tt.newData))
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// lcs returns the length of the longest common subsequence.
+	lcs := func(a, b []string) int {
+		prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+		for i := range a {
+			for j := range b {
+				if a[i] == b[j] {
+					cur[j+1] = prev[j] + 1
+				} else {
+					cur[j+1] = max(prev[j+1], cur[j])
+				}
+			}
+			prev, cur = cur, prev
+		}
+		return prev[len(b)]
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(20))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := randomLines(), randomLines()
+		gotA, gotB := []string{}, []string{}
+		var edits int
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		require.Equal(t, a, gotA, "a=%q b=%q", a, b)
+		require.Equal(t, b, gotB, "a=%q b=%q", a, b)
+		require.Equal(t, len(a)+len(b)-2*lcs(a, b), edits, "a=%q b=%q", a, b)
+	}
+
+	// Large files with a few changes.
+	a := make([]string, 100000)
+	for i := range a {
+		a[i] = fmt.Sprintf("host%d ssh-ed25519 AAAA\n", i)
+	}
+	b := append([]string{"new ssh-ed25519 AAAA\n"}, a[:50000]...)
+	b = append(b, a[50001:]...)
+	diff := UnifiedDiff("known_hosts", []byte(strings.Join(a, "")), []byte(strings.Join(b, "")))
+	assert.Equal(t, 2, strings.Count(diff, "\n@@ "))
+	assert.Contains(t, diff, "\n+new ssh-ed25519 AAAA\n")
+	assert.Contains(t, diff, "\n-host50000 ssh-ed25519 AAAA\n")
+}
@@ -0,0 +1,121 @@
+package fileutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/smallstep/cli-utils/command"
+)
+
+// Change is a modification of a file recorded in dry-run mode.
+type Change struct {
+	Filename string
+	// Old is the previous content of the file, nil if the file did not exist.
+	Old []byte
+	// New is the content that would be written.
+	New  []byte
+	Perm os.FileMode
+	// Secret is true if the content is secret, like a private key, and
+	// it must not be printed.
+	Secret bool
+}
+
+// Diff returns the unified diff of the change. The diff of secret files does
+// not contain the content.
+func (c *Change) Diff() string {
+	if c.Secret {
+		return fmt.Sprintf("Secret file %s would be written with mode %04o\n", c.Filename, c.Perm)
+	}
+	return UnifiedDiff(c.Filename, c.Old, c.New)
+}
+
+// Recorder records the changes of the fileutil write functions instead of
+// writing them to disk. Each change is printed as a unified diff to the
+// recorder writer. Subsequent reads of a changed file return the recorded
+// content, so multiple modifications of the same file are shown as
+// incremental diffs.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	changes []Change
+	files   map[string][]byte
+}
+
+// NewRecorder creates a new recorder that prints the diffs to the given
+// writer. If w is nil the diffs are not printed.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:     w,
+		files: make(map[string][]byte),
+	}
+}
+
+// Changes returns the changes recorded.
+func (r *Recorder) Changes() []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := make([]Change, len(r.changes))
+	copy(changes, r.changes)
+	return changes
+}
+
+// read returns the recorded content of the given file and true, or false if
+// the file has not been modified.
+func (r *Recorder) read(filename string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.files[filename]
+	return b, ok
+}
+
+// record records a change and prints its diff.
+func (r *Recorder) record(c Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.Old != nil && bytes.Equal(c.Old, c.New) {
+		return nil
+	}
+	r.changes = append(r.changes, c)
+	r.files[c.Filename] = c.New
+	if r.w == nil {
+		return nil
+	}
+	_, err := io.WriteString(r.w, c.Diff())
+	return err
+}
+
+var (
+	recorderMu      sync.RWMutex
+	currentRecorder *Recorder
+	defaultRecorder = NewRecorder(os.Stderr)
+)
+
+// SetRecorder sets the recorder used by the fileutil write functions. A nil
+// recorder disables the dry-run mode unless the dry-run flag is passed.
+func SetRecorder(r *Recorder) {
+	recorderMu.Lock()
+	currentRecorder = r
+	recorderMu.Unlock()
+}
+
+// IsDryRun returns true if the fileutil write functions are recording the
+// changes instead of writing them.
+func IsDryRun() bool {
+	return getRecorder() != nil
+}
+
+// getRecorder returns the recorder set with SetRecorder or, if the dry-run
+// flag is passed, a recorder that prints the diffs to the standard error, so
+// they are not mixed with the output of the command.
+func getRecorder() *Recorder {
+	recorderMu.RLock()
+	r := currentRecorder
+	recorderMu.RUnlock()
+	if r == nil && command.IsDryRun() {
+		r = defaultRecorder
+	}
+	return r
+}
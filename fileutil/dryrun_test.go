@@ -0,0 +1,68 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	missing := filepath.Join(dir, "missing")
+	require.NoError(t, os.WriteFile(existing, []byte("Host foo\n"), 0600))
+
+	var buf bytes.Buffer
+	r := NewRecorder(&buf)
+	SetRecorder(r)
+	t.Cleanup(func() { SetRecorder(nil) })
+
+	assert.True(t, IsDryRun())
+	require.NoError(t, WriteFile(missing, []byte("foo\n"), 0600))
+	require.NoError(t, AppendNewLine(existing, []byte("Host bar\n"), 0600))
+	require.NoError(t, PrependLine(existing, []byte("Include step"), 0600))
+	require.NoError(t, RemoveLine(existing, "Host foo"))
+	require.NoError(t, RemoveLine(missing, "foo"))
+	require.NoError(t, RemoveLine(filepath.Join(dir, "other"), "foo"))
+
+	// Files are not modified
+	b, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "Host foo\n", string(b))
+	_, err = os.Stat(missing)
+	assert.True(t, os.IsNotExist(err))
+
+	changes := r.Changes()
+	require.Len(t, changes, 5)
+	assert.Equal(t, Change{Filename: missing, Old: nil, New: []byte("foo\n"), Perm: 0600}, changes[0])
+	assert.Equal(t, "Host foo\nHost bar\n", string(changes[1].New))
+	assert.Equal(t, "Include step\nHost foo\nHost bar", string(changes[2].New))
+	assert.Equal(t, "Include step\nHost bar", string(changes[3].New))
+	// Reads see the pending content of the missing file
+	assert.Equal(t, missing, changes[4].Filename)
+	assert.Equal(t, "foo\n", string(changes[4].Old))
+	assert.Equal(t, "", string(changes[4].New))
+
+	var want string
+	for _, c := range changes {
+		want += c.Diff()
+	}
+	assert.Equal(t, want, buf.String())
+	assert.Contains(t, buf.String(), "--- /dev/null\n+++ "+missing+"\n@@ -0,0 +1 @@\n+foo\n")
+}
+
+func TestRecorder_disabled(t *testing.T) {
+	SetRecorder(nil)
+	assert.False(t, IsDryRun())
+
+	fn := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, AppendNewLine(fn, []byte("foo"), 0600))
+	require.NoError(t, AppendNewLine(fn, []byte("bar\n"), 0600))
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(b))
+}
@@ -0,0 +1,33 @@
+package fileutil
+
+import (
+	"sync"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+var (
+	fsMu      sync.RWMutex
+	currentFS fsys.FS = fsys.OS
+)
+
+// SetFS sets the file system used by the fileutil functions that read and
+// write whole files, like WriteFile, WriteSnippet, EditFile or Restore. A nil
+// file system restores the file system of the operating system. The functions
+// that return a File, like OpenFile and OpenLocked, always use the operating
+// system.
+func SetFS(f fsys.FS) {
+	if f == nil {
+		f = fsys.OS
+	}
+	fsMu.Lock()
+	currentFS = f
+	fsMu.Unlock()
+}
+
+// getFS returns the file system used by the fileutil functions.
+func getFS() fsys.FS {
+	fsMu.RLock()
+	defer fsMu.RUnlock()
+	return currentFS
+}
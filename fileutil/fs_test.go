@@ -0,0 +1,48 @@
+package fileutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+func TestSetFS(t *testing.T) {
+	mem := fsys.NewMemFS()
+	SetFS(mem)
+	t.Cleanup(func() { SetFS(nil) })
+
+	dir := filepath.Join(t.TempDir(), "missing")
+	fn := filepath.Join(dir, "config")
+	require.NoError(t, mem.MkdirAll(dir, 0700))
+
+	require.NoError(t, WriteFile(fn, []byte("line 1\n"), 0640))
+	require.NoError(t, WriteSnippet(fn, []byte("snippet\n"), 0600))
+	require.NoError(t, EditFile(fn, 0600, ReplaceLines(MatchLine("line 1"), "line 2")))
+
+	b, err := mem.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "line 2\n")
+	assert.Contains(t, string(b), "snippet\n")
+	st, err := mem.Stat(fn)
+	require.NoError(t, err)
+	assert.Equal(t, 0640, int(st.Mode().Perm()))
+
+	snippets, err := ListSnippets(fn)
+	require.NoError(t, err)
+	assert.Len(t, snippets, 1)
+
+	require.NoError(t, WriteSecretFile(filepath.Join(dir, "key"), []byte("secret")))
+	st, err = mem.Stat(filepath.Join(dir, "key"))
+	require.NoError(t, err)
+	assert.Equal(t, 0600, int(st.Mode().Perm()))
+
+	// Nothing is written to disk
+	assert.NoDirExists(t, dir)
+
+	SetFS(nil)
+	assert.True(t, fsys.IsOS(getFS()))
+}
@@ -0,0 +1,68 @@
+package fsys
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// dirFS is an fs.FS rooted at a directory of a FS.
+type dirFS struct {
+	fsys FS
+	dir  string
+}
+
+// DirFS returns an fs.FS for the tree of files rooted at the directory dir of
+// the given file system. Unlike FS, the names used by the returned file system
+// are the slash-separated paths of the io/fs package, validated with
+// fs.ValidPath, so it can be used with functions like fs.WalkDir or fs.Glob.
+// It also implements fs.ReadFileFS, fs.StatFS and fs.ReadDirFS.
+func DirFS(f FS, dir string) fs.FS {
+	return &dirFS{fsys: f, dir: dir}
+}
+
+func (d *dirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+// Open implements fs.FS.
+func (d *dirFS) Open(name string) (fs.File, error) {
+	p, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (d *dirFS) ReadFile(name string) ([]byte, error) {
+	p, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.ReadFile(p)
+}
+
+// Stat implements fs.StatFS.
+func (d *dirFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.Stat(p)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (d *dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return d.fsys.ReadDir(p)
+}
@@ -0,0 +1,157 @@
+// Package fsys defines a writable file system, with implementations backed by
+// the operating system and by memory, and an adapter to the io/fs interfaces. The fileutil
+// and step packages can be configured to use an in-memory file system, so
+// programs and tests do not depend on the files of the user.
+package fsys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File is an open file in a file system.
+type File interface {
+	fs.File
+	io.Writer
+	io.Seeker
+	Name() string
+	Sync() error
+}
+
+// FS is a writable file system. Names are operating system paths, like the
+// ones used by the os package, so FS does not implement fs.FS; use DirFS to get
+// an fs.FS with the slash-separated paths of the io/fs package.
+//
+// The errors returned are *fs.PathError, and can be checked with
+// errors.Is(err, fs.ErrNotExist) or os.IsNotExist.
+type FS interface {
+	// Open opens the named file for reading.
+	Open(name string) (File, error)
+	// ReadFile reads the named file and returns its contents.
+	ReadFile(name string) ([]byte, error)
+	// Stat returns the information of the named file.
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir reads the named directory and returns its entries sorted by
+	// name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Lstat is like Stat, but it does not follow symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+	// OpenFile opens the named file with the given flags, os.O_RDONLY,
+	// os.O_CREATE, etc., and permissions.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	// WriteFile writes data to the named file, creating it if necessary.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// MkdirAll creates a directory and all the parents that do not exist.
+	MkdirAll(path string, perm fs.FileMode) error
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+	// Rename renames oldpath to newpath, replacing newpath if it exists.
+	Rename(oldpath, newpath string) error
+	// Chmod changes the mode of the named file.
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// OSFS is the file system of the operating system. Its methods call the
+// functions of the os package.
+type OSFS struct{}
+
+// OS is the file system of the operating system.
+var OS FS = OSFS{}
+
+// IsOS returns true if the given file system is the file system of the
+// operating system.
+func IsOS(f FS) bool {
+	_, ok := f.(OSFS)
+	return ok
+}
+
+// Open calls os.Open.
+func (OSFS) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ReadFile calls os.ReadFile.
+func (OSFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+// Stat calls os.Stat.
+func (OSFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+// ReadDir calls os.ReadDir.
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// Lstat calls os.Lstat.
+func (OSFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+
+// OpenFile calls os.OpenFile.
+func (OSFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteFile calls os.WriteFile.
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// MkdirAll calls os.MkdirAll.
+func (OSFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+// Remove calls os.Remove.
+func (OSFS) Remove(name string) error { return os.Remove(name) }
+
+// Rename calls os.Rename.
+func (OSFS) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+// Chmod calls os.Chmod.
+func (OSFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+
+// Exists returns true if the named file exists in the file system.
+func Exists(f FS, name string) bool {
+	_, err := f.Stat(name)
+	return err == nil
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory as
+// name and renames it to name, so readers will either see the old or the new
+// contents, but never a partial write.
+func WriteFileAtomic(f FS, name string, data []byte, perm fs.FileMode) error {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return err
+	}
+	dir, base := filepath.Split(name)
+	tmp := filepath.Join(dir, "."+base+".tmp"+hex.EncodeToString(suffix[:]))
+
+	t, err := f.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Remove(tmp)
+
+	if _, err := t.Write(data); err != nil {
+		t.Close()
+		return err
+	}
+	if err := t.Sync(); err != nil {
+		t.Close()
+		return err
+	}
+	if err := t.Close(); err != nil {
+		return err
+	}
+	if err := f.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return f.Rename(tmp, name)
+}
@@ -0,0 +1,131 @@
+package fsys
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFileSystems(t *testing.T) map[string]struct {
+	fsys FS
+	root string
+} {
+	return map[string]struct {
+		fsys FS
+		root string
+	}{
+		"os":  {OS, t.TempDir()},
+		"mem": {NewMemFS(), filepath.Join(string(filepath.Separator), "home", "user")},
+	}
+}
+
+func TestFS(t *testing.T) {
+	for name, tc := range testFileSystems(t) {
+		t.Run(name, func(t *testing.T) {
+			f, root := tc.fsys, tc.root
+			path := func(elem ...string) string {
+				return filepath.Join(append([]string{root}, elem...)...)
+			}
+
+			require.NoError(t, f.MkdirAll(path("a", "b"), 0700))
+			require.NoError(t, f.MkdirAll(path("a", "b"), 0700))
+			require.NoError(t, f.WriteFile(path("a", "file.txt"), []byte("hello"), 0600))
+			require.NoError(t, WriteFileAtomic(f, path("a", "b", "atomic.txt"), []byte("atomic"), 0640))
+
+			// Errors
+			_, err := f.ReadFile(path("missing"))
+			assert.True(t, os.IsNotExist(err))
+			assert.True(t, errors.Is(err, fs.ErrNotExist))
+			assert.Error(t, f.WriteFile(path("missing", "file.txt"), nil, 0600))
+			assert.Error(t, f.MkdirAll(path("a", "file.txt", "c"), 0700))
+			assert.Error(t, f.Remove(path("a")))
+			_, err = f.OpenFile(path("a", "file.txt"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+			assert.True(t, errors.Is(err, fs.ErrExist))
+
+			// Read
+			b, err := f.ReadFile(path("a", "file.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(b))
+			st, err := f.Stat(path("a", "b", "atomic.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "atomic.txt", st.Name())
+			assert.Equal(t, int64(6), st.Size())
+			if runtime.GOOS != "windows" {
+				assert.Equal(t, fs.FileMode(0640), st.Mode().Perm())
+			}
+			st, err = f.Lstat(path("a", "b"))
+			require.NoError(t, err)
+			assert.True(t, st.IsDir())
+			entries, err := f.ReadDir(path("a"))
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "b", entries[0].Name())
+			assert.True(t, entries[0].IsDir())
+			assert.Equal(t, "file.txt", entries[1].Name())
+			assert.True(t, Exists(f, path("a", "file.txt")))
+			assert.False(t, Exists(f, path("a", "missing")))
+
+			// Append and seek
+			w, err := f.OpenFile(path("a", "file.txt"), os.O_RDWR|os.O_APPEND, 0600)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(" world"))
+			require.NoError(t, err)
+			_, err = w.Seek(6, io.SeekStart)
+			require.NoError(t, err)
+			b, err = io.ReadAll(w)
+			require.NoError(t, err)
+			assert.Equal(t, "world", string(b))
+			require.NoError(t, w.Sync())
+			require.NoError(t, w.Close())
+
+			// Rename, chmod and remove
+			require.NoError(t, f.Rename(path("a", "file.txt"), path("a", "b", "renamed.txt")))
+			require.NoError(t, f.Chmod(path("a", "b", "renamed.txt"), 0644))
+			b, err = f.ReadFile(path("a", "b", "renamed.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(b))
+			err = f.Rename(path("a", "b"), path("a", "b", "inside"))
+			if assert.Error(t, err) && runtime.GOOS != "windows" {
+				assert.True(t, errors.Is(err, syscall.EINVAL))
+			}
+			require.NoError(t, f.Rename(path("a", "b"), path("c")))
+			assert.True(t, Exists(f, path("c", "renamed.txt")))
+			assert.False(t, Exists(f, path("a", "b", "renamed.txt")))
+			require.NoError(t, f.Remove(path("c", "renamed.txt")))
+			require.NoError(t, f.Remove(path("c", "atomic.txt")))
+			require.NoError(t, f.Remove(path("c")))
+			entries, err = f.ReadDir(root)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, "a", entries[0].Name())
+
+			// io/fs compatibility
+			assert.Implements(t, (*fs.ReadFileFS)(nil), DirFS(f, root))
+			assert.Implements(t, (*fs.StatFS)(nil), DirFS(f, root))
+			assert.Implements(t, (*fs.ReadDirFS)(nil), DirFS(f, root))
+			require.NoError(t, f.MkdirAll(path("dir", "sub"), 0700))
+			require.NoError(t, f.WriteFile(path("dir", "sub", "file"), []byte("content"), 0600))
+			require.NoError(t, f.WriteFile(path("dir", "other"), []byte("other"), 0600))
+			assert.NoError(t, fstest.TestFS(DirFS(f, root), "a", "dir/sub/file", "dir/other"))
+			b, err = fs.ReadFile(DirFS(f, root), "dir/sub/file")
+			require.NoError(t, err)
+			assert.Equal(t, "content", string(b))
+			_, err = DirFS(f, root).Open("../escape")
+			assert.True(t, errors.Is(err, fs.ErrInvalid))
+		})
+	}
+}
+
+func TestIsOS(t *testing.T) {
+	assert.True(t, IsOS(OS))
+	assert.False(t, IsOS(NewMemFS()))
+}
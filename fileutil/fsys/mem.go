@@ -0,0 +1,440 @@
+package fsys
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+	errClosed   = fs.ErrClosed
+)
+
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (n *memNode) isDir() bool {
+	return n.mode.IsDir()
+}
+
+// MemFS is an in-memory file system. It is safe for concurrent use. Symbolic
+// links are not supported, so Lstat is equivalent to Stat. The root directory
+// and the current directory "." always exist.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+// NewMemFS creates a new empty in-memory file system.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: make(map[string]*memNode),
+	}
+}
+
+// isRoot returns true if the cleaned path is the root or the current
+// directory.
+func isRoot(p string) bool {
+	return p == "." || filepath.Dir(p) == p
+}
+
+// lookup returns the node of the cleaned path; the caller must hold the lock.
+func (m *MemFS) lookup(p string) (*memNode, bool) {
+	if isRoot(p) {
+		return &memNode{mode: fs.ModeDir | 0755}, true
+	}
+	n, ok := m.nodes[p]
+	return n, ok
+}
+
+// checkParent returns an error if the parent directory of the cleaned path
+// does not exist; the caller must hold the lock.
+func (m *MemFS) checkParent(op, p string) error {
+	parent, ok := m.lookup(filepath.Dir(p))
+	switch {
+	case !ok:
+		return &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+	case !parent.isDir():
+		return &fs.PathError{Op: op, Path: p, Err: errNotDir}
+	default:
+		return nil
+	}
+}
+
+// Open opens the named file for reading.
+func (m *MemFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file with the given flags and permissions.
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p := filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.lookup(p)
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case !ok:
+		if err := m.checkParent("open", p); err != nil {
+			return nil, err
+		}
+		n = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case n.isDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	case flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		n.data, n.modTime = nil, time.Now()
+	}
+	return &memFile{fs: m, name: name, path: p, node: n, flag: flag}, nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	p := filepath.Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.lookup(p)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case n.isDir():
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	default:
+		return append([]byte{}, n.data...), nil
+	}
+}
+
+// Stat returns the information of the named file.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	p := filepath.Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.lookup(p)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return newFileInfo(p, n), nil
+}
+
+// Lstat is equivalent to Stat.
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.Stat(name)
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p := filepath.Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.lookup(p)
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case !n.isDir():
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errNotDir}
+	default:
+		return m.readDir(p), nil
+	}
+}
+
+// readDir returns the entries of the cleaned directory path; the caller must
+// hold the lock.
+func (m *MemFS) readDir(p string) []fs.DirEntry {
+	var entries []fs.DirEntry
+	for k, n := range m.nodes {
+		if filepath.Dir(k) == p && k != p {
+			entries = append(entries, fs.FileInfoToDirEntry(newFileInfo(k, n)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// WriteFile writes data to the named file, creating it if necessary.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MkdirAll creates a directory and all the parents that do not exist.
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	p := filepath.Clean(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	for dir := p; ; dir = filepath.Dir(dir) {
+		n, ok := m.lookup(dir)
+		if ok {
+			if !n.isDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+			}
+			break
+		}
+		missing = append(missing, dir)
+	}
+	for _, dir := range missing {
+		m.nodes[dir] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (m *MemFS) Remove(name string) error {
+	p := filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[p]
+	switch {
+	case !ok:
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	case n.isDir() && len(m.readDir(p)) > 0:
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	default:
+		delete(m.nodes, p)
+		return nil
+	}
+}
+
+// Rename renames oldpath to newpath, replacing newpath if it is a file.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	oldp, newp := filepath.Clean(oldpath), filepath.Clean(newpath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	n, ok := m.nodes[oldp]
+	if !ok {
+		return linkErr(fs.ErrNotExist)
+	}
+	if err := m.checkParent("rename", newp); err != nil {
+		return linkErr(fs.ErrNotExist)
+	}
+	if dst, ok := m.nodes[newp]; ok && dst.isDir() {
+		return linkErr(fs.ErrExist)
+	}
+	if oldp == newp {
+		return nil
+	}
+	prefix := oldp + string(filepath.Separator)
+	if n.isDir() && strings.HasPrefix(newp, prefix) {
+		return linkErr(syscall.EINVAL)
+	}
+
+	// Collect the descendants before modifying the map.
+	var keys []string
+	if n.isDir() {
+		for k := range m.nodes {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	delete(m.nodes, oldp)
+	m.nodes[newp] = n
+	for _, k := range keys {
+		v := m.nodes[k]
+		delete(m.nodes, k)
+		m.nodes[filepath.Join(newp, strings.TrimPrefix(k, prefix))] = v
+	}
+	return nil
+}
+
+// Chmod changes the permissions of the named file.
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	p := filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[p]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	n.mode = (n.mode &^ fs.ModePerm) | mode.Perm()
+	return nil
+}
+
+// fileInfo implements fs.FileInfo for the nodes of a MemFS.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(p string, n *memNode) *fileInfo {
+	return &fileInfo{
+		name:    filepath.Base(p),
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// memFile is an open file of a MemFS.
+type memFile struct {
+	fs      *MemFS
+	name    string
+	path    string
+	node    *memNode
+	flag    int
+	offset  int64
+	entries []fs.DirEntry
+	closed  bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: errClosed}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return newFileInfo(f.path, f.node), nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errClosed}
+	}
+	if f.node.isDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errIsDir}
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: errClosed}
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	if end := f.offset + int64(len(b)); end > int64(len(f.node.data)) {
+		data := make([]byte, end)
+		copy(data, f.node.data)
+		f.node.data = data
+	}
+	copy(f.node.data[f.offset:], b)
+	f.offset += int64(len(b))
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errClosed}
+	}
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: errClosed}
+	}
+	if !f.node.isDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: errNotDir}
+	}
+	if f.entries == nil {
+		f.fs.mu.RLock()
+		f.entries = f.fs.readDir(f.path)
+		f.fs.mu.RUnlock()
+		if f.entries == nil {
+			f.entries = []fs.DirEntry{}
+		}
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = f.entries[len(f.entries):]
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+func (f *memFile) Sync() error {
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: errClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: errClosed}
+	}
+	f.closed = true
+	return nil
+}
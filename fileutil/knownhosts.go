@@ -0,0 +1,212 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KnownHost is an entry in an OpenSSH known_hosts file, like:
+//
+//	@cert-authority *.example.com ecdsa-sha2-nistp256 AAAA... comment
+//
+// Comments, blank lines and lines that cannot be parsed have an empty KeyType;
+// they are kept as they are.
+type KnownHost struct {
+	// Marker is the optional marker, "@cert-authority" or "@revoked".
+	Marker string
+	// Hosts are the host patterns or the hashed host names.
+	Hosts   []string
+	KeyType string
+	Key     string
+	Comment string
+	raw     string
+}
+
+// IsComment returns true if the line is a comment, a blank line or a line
+// that cannot be parsed.
+func (h KnownHost) IsComment() bool {
+	return h.KeyType == ""
+}
+
+// HasHost returns true if the entry has the given host pattern.
+func (h KnownHost) HasHost(host string) bool {
+	for _, s := range h.Hosts {
+		if s == host {
+			return true
+		}
+	}
+	return false
+}
+
+// equal returns true if both entries have the same marker, hosts and key.
+func (h KnownHost) equal(o KnownHost) bool {
+	return h.Marker == o.Marker && strings.Join(h.Hosts, ",") == strings.Join(o.Hosts, ",") &&
+		h.KeyType == o.KeyType && h.Key == o.Key
+}
+
+func (h KnownHost) String() string {
+	if h.raw != "" || h.IsComment() {
+		return h.raw
+	}
+	fields := make([]string, 0, 5)
+	if h.Marker != "" {
+		fields = append(fields, h.Marker)
+	}
+	fields = append(fields, strings.Join(h.Hosts, ","), h.KeyType, h.Key)
+	if h.Comment != "" {
+		fields = append(fields, h.Comment)
+	}
+	return strings.Join(fields, " ")
+}
+
+// sshMaxLineLength is the maximum length of a line in a known_hosts or ssh
+// config file.
+const sshMaxLineLength = 1024 * 1024
+
+// parseKnownHost parses a line of a known_hosts file. Lines that cannot be
+// parsed are returned as comments, like OpenSSH, that ignores them.
+func parseKnownHost(raw string) KnownHost {
+	s := strings.TrimSpace(raw)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return KnownHost{raw: raw}
+	}
+	var marker string
+	fields := strings.Fields(s)
+	if strings.HasPrefix(fields[0], "@") {
+		marker, fields = fields[0], fields[1:]
+	}
+	if len(fields) < 3 {
+		return KnownHost{raw: raw}
+	}
+	return KnownHost{
+		Marker:  marker,
+		Hosts:   strings.Split(fields[0], ","),
+		KeyType: fields[1],
+		Key:     fields[2],
+		Comment: strings.Join(fields[3:], " "),
+		raw:     raw,
+	}
+}
+
+// KnownHosts is an OpenSSH known_hosts file.
+type KnownHosts struct {
+	Entries []KnownHost
+	format  lineFormat
+}
+
+// ParseKnownHosts parses an OpenSSH known_hosts file. Comments, blank lines
+// and lines that cannot be parsed are preserved.
+func ParseKnownHosts(r io.Reader) (*KnownHosts, error) {
+	k := new(KnownHosts)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), sshMaxLineLength)
+	scanner.Split(scanLinesEOL)
+	for scanner.Scan() {
+		k.Entries = append(k.Entries, parseKnownHost(k.format.scan(scanner.Text())))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error parsing known_hosts")
+	}
+	return k, nil
+}
+
+// Bytes returns the content of the known_hosts file.
+func (k *KnownHosts) Bytes() []byte {
+	var buf bytes.Buffer
+	eol := k.format.lineEnding()
+	for _, h := range k.Entries {
+		buf.WriteString(h.String() + eol)
+	}
+	return k.format.format(buf.Bytes())
+}
+
+// Find returns the entries with the given marker and host pattern.
+func (k *KnownHosts) Find(marker, host string) []KnownHost {
+	var entries []KnownHost
+	for _, h := range k.Entries {
+		if !h.IsComment() && h.Marker == marker && h.HasHost(host) {
+			entries = append(entries, h)
+		}
+	}
+	return entries
+}
+
+// Add appends the given entry. It returns false if an entry with the same
+// marker, hosts and key already exists.
+func (k *KnownHosts) Add(entry KnownHost) bool {
+	for _, h := range k.Entries {
+		if !h.IsComment() && h.equal(entry) {
+			return false
+		}
+	}
+	entry.raw = ""
+	k.Entries = append(k.Entries, entry)
+	return true
+}
+
+// Remove removes the entries for which fn returns true. Comments are never
+// removed. It returns the number of entries removed.
+func (k *KnownHosts) Remove(fn func(KnownHost) bool) int {
+	entries := k.Entries[:0]
+	for _, h := range k.Entries {
+		if h.IsComment() || !fn(h) {
+			entries = append(entries, h)
+		}
+	}
+	n := len(k.Entries) - len(entries)
+	k.Entries = entries
+	return n
+}
+
+// RemoveHost removes the entries with the given marker and host pattern. It
+// returns the number of entries removed.
+func (k *KnownHosts) RemoveHost(marker, host string) int {
+	return k.Remove(func(h KnownHost) bool {
+		return h.Marker == marker && h.HasHost(host)
+	})
+}
+
+// ReadKnownHosts reads and parses an OpenSSH known_hosts file. It returns an
+// empty file if the file does not exist.
+func ReadKnownHosts(filename string) (*KnownHosts, error) {
+	b, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, FileError(err, filename)
+	}
+	k, err := ParseKnownHosts(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return k, nil
+}
+
+// EditKnownHosts reads the given known_hosts file, calls fn to modify it, and
+// writes it if it has changed. The file is created with 0644 permissions if
+// it does not exist.
+func EditKnownHosts(filename string, fn func(*KnownHosts) error) error {
+	old, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return FileError(err, filename)
+	}
+	k, err := ParseKnownHosts(bytes.NewReader(old))
+	if err != nil {
+		return errors.Wrapf(err, "error parsing %s", filename)
+	}
+	if err := fn(k); err != nil {
+		return err
+	}
+	data := k.Bytes()
+	if bytes.Equal(old, data) {
+		return nil
+	}
+	perm, err := fileMode(filename, 0644)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(filename, data, perm)
+}
@@ -0,0 +1,99 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKnownHosts = `# known hosts
+github.com,140.82.121.4 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl
+
+@cert-authority *.example.com ecdsa-sha2-nistp256 AAAAE2VjZHNh step ca
+|1|F1E1KeoE/eEWhi10WpGv4OdiO6Y=|3988QV0VE8wmZL7suNrYQLITLCg= ssh-rsa AAAAB3NzaC1yc2E
+`
+
+func TestParseKnownHosts(t *testing.T) {
+	k, err := ParseKnownHosts(strings.NewReader(testKnownHosts))
+	require.NoError(t, err)
+	require.Len(t, k.Entries, 5)
+	assert.Equal(t, testKnownHosts, string(k.Bytes()))
+
+	assert.Equal(t, KnownHost{
+		Marker:  "@cert-authority",
+		Hosts:   []string{"*.example.com"},
+		KeyType: "ecdsa-sha2-nistp256",
+		Key:     "AAAAE2VjZHNh",
+		Comment: "step ca",
+		raw:     "@cert-authority *.example.com ecdsa-sha2-nistp256 AAAAE2VjZHNh step ca",
+	}, k.Entries[3])
+	assert.Len(t, k.Find("", "140.82.121.4"), 1)
+	assert.Len(t, k.Find("@cert-authority", "*.example.com"), 1)
+	assert.Empty(t, k.Find("", "*.example.com"))
+
+	// Invalid lines are kept
+	invalid := "github.com ssh-rsa\n@revoked\n" + testKnownHosts
+	k, err = ParseKnownHosts(strings.NewReader(invalid))
+	require.NoError(t, err)
+	require.Len(t, k.Entries, 7)
+	assert.True(t, k.Entries[0].IsComment())
+	assert.True(t, k.Entries[1].IsComment())
+	assert.Equal(t, 1, k.RemoveHost("", "github.com"))
+	assert.Equal(t, "github.com ssh-rsa\n@revoked\n"+strings.Replace(testKnownHosts, "github.com,140.82.121.4 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n", "", 1), string(k.Bytes()))
+
+	// Long lines
+	long := "host ssh-rsa " + strings.Repeat("A", 128*1024) + "\n"
+	k, err = ParseKnownHosts(strings.NewReader(long))
+	require.NoError(t, err)
+	assert.Equal(t, long, string(k.Bytes()))
+}
+
+func TestKnownHosts_edit(t *testing.T) {
+	k, err := ParseKnownHosts(strings.NewReader(testKnownHosts))
+	require.NoError(t, err)
+
+	ca := KnownHost{Marker: "@cert-authority", Hosts: []string{"*.example.com"}, KeyType: "ssh-ed25519", Key: "AAAAnew"}
+	assert.True(t, k.Add(ca))
+	assert.False(t, k.Add(ca))
+	assert.Equal(t, 2, k.RemoveHost("@cert-authority", "*.example.com"))
+	assert.Equal(t, 1, k.Remove(func(h KnownHost) bool { return h.HasHost("github.com") }))
+	assert.True(t, k.Add(ca))
+
+	assert.Equal(t, `# known hosts
+
+|1|F1E1KeoE/eEWhi10WpGv4OdiO6Y=|3988QV0VE8wmZL7suNrYQLITLCg= ssh-rsa AAAAB3NzaC1yc2E
+@cert-authority *.example.com ssh-ed25519 AAAAnew
+`, string(k.Bytes()))
+}
+
+func TestEditKnownHosts(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "known_hosts")
+	ca := KnownHost{Marker: "@cert-authority", Hosts: []string{"*"}, KeyType: "ssh-ed25519", Key: "AAAA"}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, EditKnownHosts(fn, func(k *KnownHosts) error {
+			k.Add(ca)
+			return nil
+		}))
+	}
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "@cert-authority * ssh-ed25519 AAAA\n", string(b))
+
+	k, err := ReadKnownHosts(fn)
+	require.NoError(t, err)
+	assert.Len(t, k.Find("@cert-authority", "*"), 1)
+
+	// Line endings and the missing trailing newline are preserved.
+	require.NoError(t, os.WriteFile(fn, []byte("# hosts\r\nhost ssh-rsa AAAA"), 0600))
+	require.NoError(t, EditKnownHosts(fn, func(k *KnownHosts) error {
+		k.Add(ca)
+		return nil
+	}))
+	b, err = os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "# hosts\r\nhost ssh-rsa AAAA\r\n@cert-authority * ssh-ed25519 AAAA", string(b))
+}
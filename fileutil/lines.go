@@ -0,0 +1,315 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LineMatcher reports whether a line matches. Lines are passed without the
+// line ending.
+type LineMatcher func(line string) bool
+
+// MatchLine returns a matcher for lines equal to s.
+func MatchLine(s string) LineMatcher {
+	return func(line string) bool { return line == s }
+}
+
+// MatchPrefix returns a matcher for lines starting with prefix.
+func MatchPrefix(prefix string) LineMatcher {
+	return func(line string) bool { return strings.HasPrefix(line, prefix) }
+}
+
+// MatchSubstring returns a matcher for lines containing substr.
+func MatchSubstring(substr string) LineMatcher {
+	return func(line string) bool { return strings.Contains(line, substr) }
+}
+
+// MatchRegexp returns a matcher for lines matching the regular expression.
+func MatchRegexp(re *regexp.Regexp) LineMatcher {
+	return re.MatchString
+}
+
+type lineOp int
+
+const (
+	opRemove lineOp = iota
+	opReplace
+	opInsertBefore
+	opInsertAfter
+	opPrepend
+	opAppend
+)
+
+// LineEdit is an operation applied to the lines of a file by EditLines and
+// EditFile.
+type LineEdit struct {
+	op      lineOp
+	match   LineMatcher
+	lines   []string
+	limit   int
+	matched int
+}
+
+// RemoveLines removes all the lines that match.
+func RemoveLines(m LineMatcher) LineEdit {
+	return LineEdit{op: opRemove, match: m}
+}
+
+// RemoveFirstLine removes the first line that matches.
+func RemoveFirstLine(m LineMatcher) LineEdit {
+	return LineEdit{op: opRemove, match: m, limit: 1}
+}
+
+// ReplaceLines replaces all the lines that match with the given lines.
+func ReplaceLines(m LineMatcher, lines ...string) LineEdit {
+	return LineEdit{op: opReplace, match: m, lines: lines}
+}
+
+// InsertBefore inserts the given lines before the first line that matches. The
+// lines are not inserted if no line matches.
+func InsertBefore(m LineMatcher, lines ...string) LineEdit {
+	return LineEdit{op: opInsertBefore, match: m, lines: lines, limit: 1}
+}
+
+// InsertAfter inserts the given lines after the first line that matches. The
+// lines are not inserted if no line matches.
+func InsertAfter(m LineMatcher, lines ...string) LineEdit {
+	return LineEdit{op: opInsertAfter, match: m, lines: lines, limit: 1}
+}
+
+// PrependLines inserts the given lines at the beginning of the file.
+func PrependLines(lines ...string) LineEdit {
+	return LineEdit{op: opPrepend, lines: lines}
+}
+
+// AppendLines inserts the given lines at the end of the file.
+func AppendLines(lines ...string) LineEdit {
+	return LineEdit{op: opAppend, lines: lines}
+}
+
+// matches returns true if the edit applies to the given line.
+func (e *LineEdit) matches(line string) bool {
+	if e.match == nil || (e.limit > 0 && e.matched >= e.limit) || !e.match(line) {
+		return false
+	}
+	e.matched++
+	return true
+}
+
+// splitEOL splits a line in its content and its line ending, "\n", "\r\n" or
+// empty if the line has none.
+func splitEOL(s string) (line, eol string) {
+	if !strings.HasSuffix(s, "\n") {
+		return s, ""
+	}
+	line, eol = strings.TrimSuffix(s, "\n"), "\n"
+	if strings.HasSuffix(line, "\r") {
+		line, eol = strings.TrimSuffix(line, "\r"), "\r\n"
+	}
+	return line, eol
+}
+
+// scanLinesEOL is a bufio.SplitFunc like bufio.ScanLines, but the lines
+// returned keep their line ending.
+func scanLinesEOL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// lineFormat is the line ending of a file, and whether the file ends with a
+// newline. It is used by the parsers of the OpenSSH files to write them back
+// with the same format, as EditLines does.
+type lineFormat struct {
+	eol        string
+	noFinalEOL bool
+}
+
+// scan returns the content of a line read with scanLinesEOL, and records the
+// format of the file. The first line ending found is used for all the lines.
+func (f *lineFormat) scan(s string) string {
+	line, eol := splitEOL(s)
+	if f.eol == "" {
+		f.eol = eol
+	}
+	f.noFinalEOL = eol == ""
+	return line
+}
+
+// lineEnding returns the line ending of the file, LF by default.
+func (f *lineFormat) lineEnding() string {
+	if f.eol == "" {
+		return "\n"
+	}
+	return f.eol
+}
+
+// format removes the final line ending of b if the original file did not
+// have one.
+func (f *lineFormat) format(b []byte) []byte {
+	if f.noFinalEOL {
+		return bytes.TrimSuffix(b, []byte(f.lineEnding()))
+	}
+	return b
+}
+
+// lineWriter writes lines keeping the line ending of the original lines. The
+// inserted lines use the first line ending found, or LF. The line ending of
+// the last line written is pending until another line is written, so it can
+// be dropped if the input does not end with a newline.
+type lineWriter struct {
+	w       *bufio.Writer
+	eol     string
+	pending string
+}
+
+// writeLine writes a line read from the input, with its line ending, or the
+// default one if it has none.
+func (lw *lineWriter) writeLine(line, eol string) {
+	if eol == "" {
+		eol = lw.eol
+	}
+	lw.w.WriteString(lw.pending)
+	lw.w.WriteString(line)
+	lw.pending = eol
+}
+
+// insert writes new lines.
+func (lw *lineWriter) insert(lines []string) {
+	for _, l := range lines {
+		lw.writeLine(l, lw.eol)
+	}
+}
+
+// flush writes the pending line ending if trailingEOL is true, and flushes
+// the writer.
+func (lw *lineWriter) flush(trailingEOL bool) error {
+	if trailingEOL {
+		lw.w.WriteString(lw.pending)
+	}
+	return lw.w.Flush()
+}
+
+// EditLines reads lines from r, applies the edits in order and writes the
+// result to w. Each line is evaluated by all the edits, and a line removed or
+// replaced by an edit is not seen by the following ones. Blank lines, line
+// endings, and the presence or absence of a trailing newline are preserved.
+func EditLines(r io.Reader, w io.Writer, edits ...LineEdit) error {
+	// Copy the edits, so the match counters are not shared.
+	edits = append([]LineEdit(nil), edits...)
+
+	br := bufio.NewReader(r)
+	lw := &lineWriter{w: bufio.NewWriter(w)}
+
+	// Prepended lines are written before the first line of the file is. The
+	// output ends with a newline unless the input does not.
+	var prepended bool
+	trailingEOL := true
+	prepend := func() {
+		if prepended {
+			return
+		}
+		prepended = true
+		for _, e := range edits {
+			if e.op == opPrepend {
+				lw.insert(e.lines)
+			}
+		}
+	}
+
+	for {
+		s, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if s == "" {
+			break
+		}
+		line, eol := splitEOL(s)
+		if lw.eol == "" {
+			lw.eol = eol
+			if eol == "" {
+				lw.eol = "\n"
+			}
+		}
+		trailingEOL = eol != ""
+		editLine(lw, edits, line, eol, prepend)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if lw.eol == "" {
+		lw.eol = "\n"
+	}
+	prepend()
+	for _, e := range edits {
+		if e.op == opAppend {
+			lw.insert(e.lines)
+		}
+	}
+	return lw.flush(trailingEOL)
+}
+
+func editLine(lw *lineWriter, edits []LineEdit, line, eol string, prepend func()) {
+	var after []string
+	for i := range edits {
+		e := &edits[i]
+		if !e.matches(line) {
+			continue
+		}
+		switch e.op {
+		case opRemove:
+			prepend()
+			lw.insert(after)
+			return
+		case opReplace:
+			prepend()
+			lw.insert(e.lines)
+			lw.insert(after)
+			return
+		case opInsertBefore:
+			prepend()
+			lw.insert(e.lines)
+		case opInsertAfter:
+			after = append(after, e.lines...)
+		}
+	}
+	prepend()
+	lw.writeLine(line, eol)
+	lw.insert(after)
+}
+
+// EditFile applies the given edits to the lines of a file, see EditLines. If
+// the file does not exist, the edits are applied to an empty file, and the
+// file is created with the given permissions if the result is not empty. The
+// file is only written if its content changes.
+func EditFile(filename string, perm os.FileMode, edits ...LineEdit) error {
+	perm, err := fileMode(filename, perm)
+	if err != nil {
+		return err
+	}
+
+	b, err := readFile(filename)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return FileError(err, filename)
+	}
+
+	var buf bytes.Buffer
+	if err := EditLines(bytes.NewReader(b), &buf, edits...); err != nil {
+		return FileError(err, filename)
+	}
+	if bytes.Equal(b, buf.Bytes()) && (exists || buf.Len() == 0) {
+		return nil
+	}
+	return writeConfigFile(filename, buf.Bytes(), perm)
+}
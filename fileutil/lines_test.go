@@ -0,0 +1,75 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		edits []LineEdit
+		want  string
+	}{
+		{"no edits", "a\n\nb", nil, "a\n\nb"},
+		{"remove all", "foo\nbar\n\nfoo bar\n", []LineEdit{RemoveLines(MatchPrefix("foo"))}, "bar\n\n"},
+		{"remove first", "foo\nbar\nfoo\n", []LineEdit{RemoveFirstLine(MatchLine("foo"))}, "bar\nfoo\n"},
+		{"remove regexp", "Host a\n  User b\nHost c\n", []LineEdit{RemoveLines(MatchRegexp(regexp.MustCompile(`^\s+User`)))}, "Host a\nHost c\n"},
+		{"remove substring", "Include a\nInclude b", []LineEdit{RemoveLines(MatchSubstring("b"))}, "Include a"},
+		{"replace", "a\nb\na\n", []LineEdit{ReplaceLines(MatchLine("a"), "c", "d")}, "c\nd\nb\nc\nd\n"},
+		{"insert before", "a\nb\nb\n", []LineEdit{InsertBefore(MatchLine("b"), "x")}, "a\nx\nb\nb\n"},
+		{"insert after", "a\nb\nb", []LineEdit{InsertAfter(MatchLine("b"), "x")}, "a\nb\nx\nb"},
+		{"insert after last", "a\nb", []LineEdit{InsertAfter(MatchLine("b"), "x")}, "a\nb\nx"},
+		{"insert after last newline", "a\nb\n", []LineEdit{InsertAfter(MatchLine("b"), "x")}, "a\nb\nx\n"},
+		{"insert no match", "a\n", []LineEdit{InsertAfter(MatchLine("b"), "x")}, "a\n"},
+		{"prepend", "\na\n", []LineEdit{PrependLines("x", "y")}, "x\ny\n\na\n"},
+		{"prepend no newline", "a", []LineEdit{PrependLines("x")}, "x\na"},
+		{"prepend empty", "", []LineEdit{PrependLines("x")}, "x\n"},
+		{"append", "a\n", []LineEdit{AppendLines("x")}, "a\nx\n"},
+		{"append no newline", "a", []LineEdit{AppendLines("x")}, "a\nx"},
+		{"remove last no newline", "a\nb", []LineEdit{RemoveLines(MatchLine("b"))}, "a"},
+		{"replace last no newline", "a\r\nb", []LineEdit{ReplaceLines(MatchLine("b"), "c", "d")}, "a\r\nc\r\nd"},
+		{"crlf", "a\r\nb\r\n", []LineEdit{InsertAfter(MatchLine("a"), "x"), AppendLines("y")}, "a\r\nx\r\nb\r\ny\r\n"},
+		{"prepend and remove first", "a\nb\n", []LineEdit{RemoveLines(MatchLine("a")), PrependLines("a")}, "a\nb\n"},
+		{"order", "a\n", []LineEdit{RemoveLines(MatchLine("a")), InsertAfter(MatchLine("a"), "x")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EditLines(strings.NewReader(tt.input), &buf, tt.edits...))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestEditFile(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "config")
+
+	// Missing files are not created if the result is empty
+	require.NoError(t, EditFile(fn, 0600, RemoveLines(MatchLine("foo"))))
+	_, err := os.Stat(fn)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, EditFile(fn, 0600, AppendLines("Host *", "", "Host foo")))
+	require.NoError(t, EditFile(fn, 0600, RemoveLines(MatchLine("Host *")), PrependLines("Include step")))
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "Include step\n\nHost foo\n", string(b))
+
+	// The same edits are reusable
+	edit := InsertAfter(MatchLine("Include step"), "Include other")
+	require.NoError(t, EditFile(fn, 0600, edit))
+	require.NoError(t, EditFile(fn, 0600, RemoveFirstLine(MatchLine("Include other")), edit))
+	b, err = os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "Include step\nInclude other\n\nHost foo\n", string(b))
+}
@@ -0,0 +1,126 @@
+package fileutil
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LockMode is the mode of an advisory file lock.
+type LockMode int
+
+const (
+	// LockShared is a shared lock, multiple processes can hold a shared lock
+	// on the same file. It is usually used for reading.
+	LockShared LockMode = iota
+	// LockExclusive is an exclusive lock, only one process can hold it. It is
+	// usually used for writing.
+	LockExclusive
+)
+
+// ErrLockTimeout is the error returned if a lock cannot be acquired before the
+// timeout expires.
+var ErrLockTimeout = errors.New("timeout acquiring file lock")
+
+// lockPollInterval is the maximum time between attempts to acquire a lock with
+// a timeout.
+const lockPollInterval = 100 * time.Millisecond
+
+// LockOption is the type of the options used to acquire a file lock.
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	timeout time.Duration
+}
+
+// WithLockTimeout sets the maximum time to wait for a lock. If the lock is not
+// acquired in time, ErrLockTimeout is returned. By default, Lock waits until
+// the lock is available.
+func WithLockTimeout(d time.Duration) LockOption {
+	return func(o *lockOptions) {
+		o.timeout = d
+	}
+}
+
+// Lock acquires an advisory lock on the file with the given mode, blocking
+// until it is available or the timeout expires. Calling Lock on a file that is
+// already locked by the same File converts the lock to the given mode. The
+// conversion is not atomic: on unix flock releases the previous lock first,
+// and on windows, where LockFileEx would stack a second lock, it is released
+// with UnlockFileEx. Another process can acquire the lock in between, and the
+// previous lock is lost if the new one cannot be acquired. The lock is
+// released with Unlock or when the file is closed.
+//
+// Advisory locks only work between processes that use them, and they are not
+// supported on all platforms, on those platforms Lock does nothing.
+func (f *File) Lock(mode LockMode, opts ...LockOption) error {
+	if f.err != nil {
+		return f.err
+	}
+	o := new(lockOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.timeout <= 0 {
+		if _, err := lockFile(f.File, mode == LockExclusive, true); err != nil {
+			return FileError(err, f.File.Name())
+		}
+		return nil
+	}
+
+	deadline := time.Now().Add(o.timeout)
+	wait := 5 * time.Millisecond
+	for {
+		ok, err := f.TryLock(mode)
+		if err != nil || ok {
+			return err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errors.Wrapf(ErrLockTimeout, "error locking %s", f.File.Name())
+		}
+		time.Sleep(min(wait, remaining))
+		wait = min(2*wait, lockPollInterval)
+	}
+}
+
+// TryLock tries to acquire an advisory lock on the file with the given mode
+// without blocking. It returns false if the lock is held by another process.
+func (f *File) TryLock(mode LockMode) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	ok, err := lockFile(f.File, mode == LockExclusive, false)
+	if err != nil {
+		return false, FileError(err, f.File.Name())
+	}
+	return ok, nil
+}
+
+// Unlock releases the advisory lock on the file.
+func (f *File) Unlock() error {
+	if f.err != nil {
+		return f.err
+	}
+	if err := unlockFile(f.File); err != nil {
+		return FileError(err, f.File.Name())
+	}
+	return nil
+}
+
+// OpenLocked opens the named file like OpenFile and acquires an advisory lock
+// on it with the given mode, see Lock. The lock is released when the file is
+// closed.
+func OpenLocked(name string, flag int, perm os.FileMode, mode LockMode, opts ...LockOption) (*File, error) {
+	f, err := OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Lock(mode, opts...); err != nil {
+		f.File.Close()
+		return nil, err
+	}
+	return f, nil
+}
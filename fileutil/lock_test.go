@@ -0,0 +1,59 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Lock(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "lock")
+
+	f1, err := OpenLocked(fn, os.O_RDWR|os.O_CREATE, 0600, LockExclusive)
+	require.NoError(t, err)
+	f2, err := OpenFile(fn, os.O_RDWR, 0600)
+	require.NoError(t, err)
+	t.Cleanup(func() { f2.Close() })
+
+	// Exclusive lock held by f1
+	ok, err := f2.TryLock(LockShared)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	err = f2.Lock(LockExclusive, WithLockTimeout(20*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrLockTimeout))
+
+	_, err = OpenLocked(fn, os.O_RDWR, 0600, LockShared, WithLockTimeout(10*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrLockTimeout))
+
+	// Lock with a timeout waits for the lock
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		done <- f1.Unlock()
+	}()
+	require.NoError(t, f2.Lock(LockShared, WithLockTimeout(5*time.Second)))
+	require.NoError(t, <-done)
+
+	// Shared locks
+	ok, err = f1.TryLock(LockShared)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = f1.TryLock(LockExclusive)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Close releases the lock
+	require.NoError(t, f2.Close())
+	ok, err = f1.TryLock(LockExclusive)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, f1.Close())
+
+	// Closed files cannot be locked
+	assert.Error(t, f1.Lock(LockShared))
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile locks the file using LockFileEx. If block is false and the lock is
+// held by another process, it returns false. LockFileEx does not convert an
+// existing lock, it would add a second one, so the lock held by f, if any, is
+// released first.
+func lockFile(f *os.File, exclusive, block bool) (bool, error) {
+	if err := unlockFile(f); err != nil && !errors.Is(err, windows.ERROR_NOT_LOCKED) {
+		return false, err
+	}
+
+	var flags uint32
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !block {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	ol := new(windows.Overlapped)
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, ^uint32(0), ^uint32(0), ol)
+	switch {
+	case err == nil:
+		return true, nil
+	case !block && errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		return false, nil
+	default:
+		return false, &os.PathError{Op: "LockFileEx", Path: f.Name(), Err: err}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	if err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, ^uint32(0), ^uint32(0), ol); err != nil {
+		return &os.PathError{Op: "UnlockFileEx", Path: f.Name(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/smallstep/cli-utils/step"
+)
+
+func TestMain(m *testing.M) {
+	// Backups are stored in the step path.
+	dir, err := os.MkdirTemp("", "fileutil")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv(step.PathEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,146 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+// OverwritePolicy defines what WriteFile does if the file already exists.
+type OverwritePolicy string
+
+const (
+	// OverwritePrompt asks the user before overwriting the file. This is the
+	// default policy.
+	OverwritePrompt OverwritePolicy = "prompt"
+	// OverwriteNever never overwrites the file and returns ErrFileExists.
+	OverwriteNever OverwritePolicy = "never"
+	// OverwriteAlways overwrites the file without asking.
+	OverwriteAlways OverwritePolicy = "overwrite"
+	// OverwriteBackup copies the existing file to a sibling with the .bak
+	// extension, or .bak.N if it exists, and overwrites it.
+	OverwriteBackup OverwritePolicy = "backup"
+	// OverwriteNew keeps the existing file and writes the data to a sibling
+	// with the .new extension, or .new.N if it exists. WriteFile returns a
+	// *NotOverwrittenError with the path written.
+	OverwriteNew OverwritePolicy = "new"
+)
+
+// ParseOverwritePolicy parses the given overwrite policy. An empty string is
+// parsed as OverwritePrompt.
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	switch p := OverwritePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return OverwritePrompt, nil
+	case OverwritePrompt, OverwriteNever, OverwriteAlways, OverwriteBackup, OverwriteNew:
+		return p, nil
+	default:
+		return "", errors.Errorf("invalid overwrite policy '%s'; options are prompt, never, overwrite, backup, new", s)
+	}
+}
+
+// WriteOption is the type of the options used by WriteFile.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	policy OverwritePolicy
+}
+
+// WithOverwritePolicy sets the policy used if the file already exists. It
+// takes precedence over the overwrite and force flags, and the environment
+// variable STEP_OVERWRITE.
+func WithOverwritePolicy(p OverwritePolicy) WriteOption {
+	return func(o *writeOptions) {
+		o.policy = p
+	}
+}
+
+// NotOverwrittenError is the error returned by WriteFile with the OverwriteNew
+// policy. The existing file has not been modified, and the data has been
+// written to Path. It wraps ErrFileExists.
+type NotOverwrittenError struct {
+	Filename string
+	Path     string
+}
+
+func (e *NotOverwrittenError) Error() string {
+	return fmt.Sprintf("%s exists; the new content has been written to %s", e.Filename, e.Path)
+}
+
+// Unwrap returns ErrFileExists.
+func (e *NotOverwrittenError) Unwrap() error {
+	return ErrFileExists
+}
+
+// writeNew writes data to the first available sibling of filename with the
+// .new or .new.N extension.
+func writeNew(filename string, data []byte, perm os.FileMode) error {
+	name, err := writeSibling(filename, ".new", data, perm)
+	if err != nil {
+		return err
+	}
+	return &NotOverwrittenError{Filename: filename, Path: name}
+}
+
+// writeSibling writes data to the first name, filename with the given
+// extension or with the extension and a numeric suffix, that does not exist,
+// and returns the name. The file is created exclusively, so concurrent writers
+// never use the same name. In dry-run mode, the change is only recorded.
+func writeSibling(filename, ext string, data []byte, perm os.FileMode) (string, error) {
+	f := getFS()
+	name := filename + ext
+	for i := 1; ; i++ {
+		if IsDryRun() {
+			if _, err := f.Lstat(name); os.IsNotExist(err) {
+				return name, writeFile(name, data, perm)
+			} else if err != nil {
+				return "", FileError(err, name)
+			}
+		} else {
+			w, err := f.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+			switch {
+			case err == nil:
+				return name, writeSiblingFile(w, name, data)
+			case !errors.Is(err, os.ErrExist):
+				return "", FileError(err, name)
+			}
+		}
+		name = fmt.Sprintf("%s%s.%d", filename, ext, i)
+	}
+}
+
+// writeSiblingFile writes data to a file created by writeSibling, and removes
+// it if the write fails.
+func writeSiblingFile(w fsys.File, name string, data []byte) error {
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Sync()
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		getFS().Remove(name)
+		return FileError(err, name)
+	}
+	return nil
+}
+
+// copyToBackup copies the given file to the first available sibling with the
+// .bak or .bak.N extension.
+func copyToBackup(filename string) error {
+	b, err := readFile(filename)
+	if err != nil {
+		return FileError(err, filename)
+	}
+	perm, err := fileMode(filename, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = writeSibling(filename, ".bak", b, perm)
+	return err
+}
@@ -0,0 +1,199 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+var (
+	// ErrSymlink is the error returned if a secret file or its parent
+	// directory is a symbolic link. Other ancestors can be symbolic links.
+	ErrSymlink = errors.New("file is a symbolic link")
+
+	// ErrNotOwner is the error returned if a secret file or its parent
+	// directory is not owned by the current user.
+	ErrNotOwner = errors.New("file is not owned by the current user")
+
+	// ErrWorldWritable is the error returned if a parent directory of a secret
+	// file can be modified by any user.
+	ErrWorldWritable = errors.New("directory is world-writable")
+
+	// ErrNotRegular is the error returned if a secret file exists and is not
+	// a regular file.
+	ErrNotRegular = errors.New("file is not a regular file")
+)
+
+// SecretFileError is the error returned by WriteSecretFile if the file cannot
+// be written securely. Err is one of ErrSymlink, ErrNotOwner, ErrWorldWritable
+// or ErrNotRegular, and can be checked using errors.Is.
+type SecretFileError struct {
+	Path string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *SecretFileError) Error() string {
+	return fmt.Sprintf("cannot write secret file: %s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the reason of the error.
+func (e *SecretFileError) Unwrap() error {
+	return e.Err
+}
+
+// WriteSecretFile atomically writes data, like a private key, to the given
+// file with 0600 permissions. The missing parent directories are created with
+// 0700 permissions, after checking the existing ones.
+//
+// The file is not written, and a *SecretFileError is returned, if the file is
+// a symbolic link or not a regular file, if the file or its parent directory
+// are owned by another user, or if any parent directory can be modified by
+// other users, except for directories with the sticky bit, like /tmp, that
+// are not the direct parent of the file. Existing files are replaced without
+// a prompt, and no backups are created. In dry-run mode the content is not
+// printed.
+func WriteSecretFile(filename string, data []byte) error {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return errors.Wrapf(err, "error getting absolute path of %s", filename)
+	}
+
+	if r := getRecorder(); r != nil {
+		old, err := readFile(filename)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return FileError(err, filename)
+			}
+			old = nil
+		}
+		return r.record(Change{
+			Filename: filename,
+			Old:      old,
+			New:      data,
+			Perm:     0600,
+			Secret:   true,
+		})
+	}
+
+	dir := filepath.Dir(filename)
+	f := getFS()
+	if !fsys.IsOS(f) {
+		// Ownership and permissions are only checked in the file system of
+		// the operating system.
+		if err := f.MkdirAll(dir, 0700); err != nil {
+			return FileError(err, dir)
+		}
+		if st, err := f.Lstat(filename); err == nil && !st.Mode().IsRegular() {
+			return &SecretFileError{Path: filename, Err: ErrNotRegular}
+		}
+		return FileError(fsys.WriteFileAtomic(f, filename, data, 0600), filename)
+	}
+
+	// Check the existing directories before creating the missing ones, and
+	// check the new ones after.
+	existing, err := existingAncestor(dir)
+	if err != nil {
+		return err
+	}
+	if existing == dir {
+		if err := checkSecretDir(dir); err != nil {
+			return err
+		}
+	} else {
+		if err := checkAncestors(existing); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return FileError(err, dir)
+		}
+		if err := checkSecretDir(dir); err != nil {
+			return err
+		}
+	}
+
+	switch st, err := os.Lstat(filename); {
+	case os.IsNotExist(err):
+	case err != nil:
+		return FileError(err, filename)
+	case st.Mode()&os.ModeSymlink != 0:
+		return &SecretFileError{Path: filename, Err: ErrSymlink}
+	case !st.Mode().IsRegular():
+		return &SecretFileError{Path: filename, Err: ErrNotRegular}
+	default:
+		if err := checkOwner(filename, st); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := writeTemp(filename, data, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return rename(tmp, filename)
+}
+
+// existingAncestor returns dir, or its closest ancestor that exists.
+func existingAncestor(dir string) (string, error) {
+	for {
+		_, err := os.Lstat(dir)
+		switch {
+		case err == nil:
+			return dir, nil
+		case !os.IsNotExist(err):
+			return "", FileError(err, dir)
+		}
+		next := filepath.Dir(dir)
+		if next == dir {
+			return dir, nil
+		}
+		dir = next
+	}
+}
+
+// checkSecretDir checks that dir, the parent directory of a secret file, is
+// not a symbolic link, is owned by the current user, and that it and its
+// ancestors cannot be modified by other users.
+func checkSecretDir(dir string) error {
+	st, err := os.Lstat(dir)
+	if err != nil {
+		return FileError(err, dir)
+	}
+	if st.Mode()&os.ModeSymlink != 0 {
+		return &SecretFileError{Path: dir, Err: ErrSymlink}
+	}
+	if err := checkOwner(dir, st); err != nil {
+		return err
+	}
+	if err := checkWritable(dir, st, true); err != nil {
+		return err
+	}
+	if next := filepath.Dir(dir); next != dir {
+		return checkAncestors(next)
+	}
+	return nil
+}
+
+// checkAncestors checks that dir and its ancestors cannot be modified by other
+// users, except for directories with the sticky bit.
+func checkAncestors(dir string) error {
+	for {
+		st, err := os.Stat(dir)
+		if err != nil {
+			return FileError(err, dir)
+		}
+		if err := checkWritable(dir, st, false); err != nil {
+			return err
+		}
+		next := filepath.Dir(dir)
+		if next == dir {
+			return nil
+		}
+		dir = next
+	}
+}
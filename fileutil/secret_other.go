@@ -0,0 +1,13 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+// checkOwner is not supported, file permissions are not based on the mode
+// bits.
+func checkOwner(string, os.FileInfo) error { return nil }
+
+// checkWritable is not supported, file permissions are not based on the mode
+// bits.
+func checkWritable(string, os.FileInfo, bool) error { return nil }
@@ -0,0 +1,91 @@
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSecretFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+	dir := t.TempDir()
+
+	t.Run("ok", func(t *testing.T) {
+		fn := filepath.Join(dir, "secrets", "key")
+		require.NoError(t, WriteSecretFile(fn, []byte("secret")))
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(b))
+		st, err := os.Stat(fn)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+		st, err = os.Stat(filepath.Dir(fn))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), st.Mode().Perm())
+
+		// Existing files are replaced with 0600
+		require.NoError(t, os.Chmod(fn, 0644))
+		require.NoError(t, WriteSecretFile(fn, []byte("new secret")))
+		b, err = os.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "new secret", string(b))
+		st, err = os.Stat(fn)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		target := filepath.Join(dir, "target")
+		link := filepath.Join(dir, "link")
+		require.NoError(t, os.WriteFile(target, []byte("target"), 0600))
+		require.NoError(t, os.Symlink(target, link))
+		err := WriteSecretFile(link, []byte("secret"))
+		var serr *SecretFileError
+		require.True(t, errors.As(err, &serr))
+		assert.Equal(t, link, serr.Path)
+		assert.True(t, errors.Is(err, ErrSymlink))
+		b, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "target", string(b))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		sub := filepath.Join(dir, "sub")
+		require.NoError(t, os.Mkdir(sub, 0700))
+		assert.True(t, errors.Is(WriteSecretFile(sub, []byte("secret")), ErrNotRegular))
+	})
+
+	t.Run("world-writable", func(t *testing.T) {
+		public := filepath.Join(dir, "public")
+		require.NoError(t, os.Mkdir(public, 0700))
+		require.NoError(t, os.Chmod(public, 0777))
+		assert.True(t, errors.Is(WriteSecretFile(filepath.Join(public, "key"), []byte("secret")), ErrWorldWritable))
+		assert.True(t, errors.Is(WriteSecretFile(filepath.Join(public, "private", "key"), []byte("secret")), ErrWorldWritable))
+		_, err := os.Stat(filepath.Join(public, "private"))
+		assert.True(t, os.IsNotExist(err), "directory created before the check")
+
+		// Sticky directories are allowed as ancestors
+		require.NoError(t, os.Chmod(public, 0777|os.ModeSticky))
+		assert.True(t, errors.Is(WriteSecretFile(filepath.Join(public, "key"), []byte("secret")), ErrWorldWritable))
+		assert.NoError(t, WriteSecretFile(filepath.Join(public, "private", "key"), []byte("secret")))
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		var buf bytes.Buffer
+		SetRecorder(NewRecorder(&buf))
+		defer SetRecorder(nil)
+		fn := filepath.Join(dir, "dry-run", "key")
+		require.NoError(t, WriteSecretFile(fn, []byte("secret")))
+		assert.Equal(t, "Secret file "+fn+" would be written with mode 0600\n", buf.String())
+		_, err := os.Stat(filepath.Dir(fn))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
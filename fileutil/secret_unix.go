@@ -0,0 +1,30 @@
+//go:build unix
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// checkOwner returns an error if the given file is not owned by the current
+// user.
+func checkOwner(name string, st os.FileInfo) error {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok && int(sys.Uid) != os.Geteuid() {
+		return &SecretFileError{Path: name, Err: ErrNotOwner}
+	}
+	return nil
+}
+
+// checkWritable returns an error if the given directory is world-writable.
+// Directories with the sticky bit are only allowed if they are not the
+// direct parent of the file.
+func checkWritable(dir string, st os.FileInfo, parent bool) error {
+	if st.Mode().Perm()&0002 == 0 {
+		return nil
+	}
+	if !parent && st.Mode()&os.ModeSticky != 0 {
+		return nil
+	}
+	return &SecretFileError{Path: dir, Err: ErrWorldWritable}
+}
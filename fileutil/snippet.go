@@ -0,0 +1,355 @@
+package fileutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/ui"
+)
+
+// ErrSnippetModified is the error returned if a snippet has been modified
+// after it was written and the changes cannot be discarded.
+var ErrSnippetModified = errors.New("snippet has been modified")
+
+// snippetHashPrefix is the prefix of the hash of the content in the snippet
+// header.
+const snippetHashPrefix = "sha256:"
+
+var (
+	commentPrefixesMu sync.RWMutex
+	commentPrefixes   = map[string]string{
+		".ini": ";",
+		".lua": "--",
+		".sql": "--",
+		".vim": `"`,
+	}
+)
+
+// RegisterCommentPrefix sets the prefix of the comments used in the snippet
+// header and footer of files with the given extension, like ".ini". The
+// default prefix is "#".
+func RegisterCommentPrefix(ext, prefix string) {
+	commentPrefixesMu.Lock()
+	commentPrefixes[strings.ToLower(ext)] = prefix
+	commentPrefixesMu.Unlock()
+}
+
+// SnippetOption is the type of the options used to write, list and remove
+// snippets.
+type SnippetOption func(*snippetOptions)
+
+type snippetOptions struct {
+	name          string
+	commentPrefix string
+}
+
+// WithSnippetName sets the name of the snippet. A file can contain multiple
+// snippets with different names. The header of a named snippet is like
+// "# autogenerated by step: <name>", and its footer "# end: <name>".
+func WithSnippetName(name string) SnippetOption {
+	return func(o *snippetOptions) {
+		o.name = name
+	}
+}
+
+// WithCommentPrefix sets the prefix of the comments used in the snippet header
+// and footer, like "//". By default, it is based on the extension of the file,
+// see RegisterCommentPrefix.
+func WithCommentPrefix(prefix string) SnippetOption {
+	return func(o *snippetOptions) {
+		o.commentPrefix = prefix
+	}
+}
+
+func newSnippetOptions(filename string, opts []SnippetOption) (*snippetOptions, error) {
+	o := new(snippetOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+	if o.commentPrefix == "" {
+		commentPrefixesMu.RLock()
+		o.commentPrefix = commentPrefixes[strings.ToLower(filepath.Ext(filename))]
+		commentPrefixesMu.RUnlock()
+	}
+	if strings.IndexFunc(o.name, func(r rune) bool { return unicode.IsSpace(r) || r == '@' || r == ':' }) >= 0 {
+		return nil, errors.Errorf("invalid snippet name '%s'", o.name)
+	}
+	return o, nil
+}
+
+// comment replaces the "#" prefix of the given default header or footer with
+// the configured comment prefix.
+func (o *snippetOptions) comment(s string) string {
+	if o.commentPrefix == "" || !strings.HasPrefix(s, "#") {
+		return s
+	}
+	return o.commentPrefix + strings.TrimPrefix(s, "#")
+}
+
+func (o *snippetOptions) header() string {
+	return o.comment(SnippetHeader)
+}
+
+func (o *snippetOptions) footer(name string) string {
+	if name == "" {
+		return o.comment(SnippetFooter)
+	}
+	return o.comment(SnippetFooter) + ": " + name
+}
+
+// Snippet is a block of a configuration file managed by step.
+type Snippet struct {
+	// Name is the name of the snippet, empty for the default snippet.
+	Name string
+	// Content is the content between the header and the footer.
+	Content []byte
+	// Modified is true if the content does not match the hash in the header,
+	// so it has been edited after it was written.
+	Modified bool
+}
+
+// snippetBlock is the location of a snippet in a file. The block goes from
+// the start of the header line to the end of the footer line.
+type snippetBlock struct {
+	Snippet
+	hash       string
+	start, end int
+}
+
+// snippetHash returns the hash of the content of a snippet, as stored in the
+// header.
+func snippetHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return snippetHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// parseHeader returns the name of the snippet and the hash of its content if
+// the line is a snippet header. Headers written by older versions do not
+// include the hash.
+func (o *snippetOptions) parseHeader(line string) (name, hash string, ok bool) {
+	rest, ok := strings.CutPrefix(line, o.header())
+	if !ok {
+		return "", "", false
+	}
+	switch {
+	case strings.HasPrefix(rest, ": "):
+		name, rest, _ = strings.Cut(rest[2:], " ")
+		if name == "" {
+			return "", "", false
+		}
+	case rest == "", strings.HasPrefix(rest, " "):
+	default:
+		return "", "", false
+	}
+	for _, f := range strings.Fields(rest) {
+		if strings.HasPrefix(f, snippetHashPrefix) {
+			hash = f
+		}
+	}
+	return name, hash, true
+}
+
+// isFooter returns true if the line is the footer of the snippet with the
+// given name.
+func (o *snippetOptions) isFooter(line, name string) bool {
+	footer := o.footer(name)
+	if name != "" {
+		return line == footer
+	}
+	return strings.HasPrefix(line, footer) && !strings.HasPrefix(line, footer+":")
+}
+
+// findSnippets returns the snippets in b. Headers without a footer are
+// ignored.
+func (o *snippetOptions) findSnippets(b []byte) []snippetBlock {
+	var (
+		blocks  []snippetBlock
+		current *snippetBlock
+		offset  int
+	)
+	for _, l := range splitLines(b) {
+		line := strings.TrimRight(l, "\r\n")
+		switch {
+		case current == nil:
+			if name, hash, ok := o.parseHeader(line); ok {
+				current = &snippetBlock{hash: hash, start: offset}
+				current.Name = name
+			}
+		case o.isFooter(line, current.Name):
+			current.end = offset + len(l)
+			current.Modified = current.hash != "" && current.hash != snippetHash(current.Content)
+			blocks = append(blocks, *current)
+			current = nil
+		default:
+			current.Content = append(current.Content, l...)
+		}
+		offset += len(l)
+	}
+	return blocks
+}
+
+func (o *snippetOptions) find(b []byte) (snippetBlock, bool) {
+	for _, s := range o.findSnippets(b) {
+		if s.Name == o.name {
+			return s, true
+		}
+	}
+	return snippetBlock{}, false
+}
+
+// confirmOverwrite asks the user to discard the changes of a modified snippet.
+// It returns ErrSnippetModified if the user declines or if it cannot prompt.
+// The changes are discarded without a prompt using the force flag or in
+// dry-run mode.
+func confirmOverwrite(filename string, s snippetBlock) error {
+	if !s.Modified || command.IsForce() || IsDryRun() {
+		return nil
+	}
+
+	desc := "snippet"
+	if s.Name != "" {
+		desc = fmt.Sprintf("snippet '%s'", s.Name)
+	}
+	if !ui.IsInteractive() {
+		return errors.Wrapf(ErrSnippetModified, "the %s in %s has been modified; use the '--force' flag to overwrite it", desc, filename)
+	}
+	str, err := ui.Prompt(fmt.Sprintf("The %s in %s has been modified, would you like to discard the changes [y/n]", desc, filename), ui.WithValidateYesNo())
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errors.Wrapf(ErrSnippetModified, "the %s in %s has been modified", desc, filename)
+	}
+}
+
+// WriteSnippet writes the given data into the given filename. It surrounds the
+// data with a default header and footer, and it will replace the previous
+// snippet with the same name, or append it to the end of the file.
+//
+// The header contains the hash of the content. If the previous snippet has been
+// edited, the user is asked to discard the changes; without a terminal, it
+// fails with ErrSnippetModified unless the force flag is used.
+func WriteSnippet(filename string, data []byte, perm os.FileMode, opts ...SnippetOption) error {
+	o, err := newSnippetOptions(filename, opts)
+	if err != nil {
+		return err
+	}
+
+	// Get file permissions
+	perm, err = fileMode(filename, perm)
+	if err != nil {
+		return err
+	}
+
+	// Read file contents
+	b, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return FileError(err, filename)
+	}
+
+	// Detect previous configuration
+	start, end := len(b), len(b)
+	if s, ok := o.find(b); ok {
+		if err := confirmOverwrite(filename, s); err != nil {
+			return err
+		}
+		start, end = s.start, s.end
+	}
+
+	// Replace previous configuration
+	var buf bytes.Buffer
+	if len(b) > 0 {
+		buf.Write(b[:start])
+		if start == end {
+			buf.WriteString("\n")
+		}
+	}
+	header := o.header()
+	if o.name != "" {
+		header += ": " + o.name
+	}
+	content := data
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content[:len(content):len(content)], '\n')
+	}
+	fmt.Fprintf(&buf, "%s @ %s %s\n", header, time.Now().UTC().Format(time.RFC3339), snippetHash(content))
+	buf.Write(content)
+	buf.WriteString(o.footer(o.name) + "\n")
+	if len(b) > 0 {
+		buf.Write(b[end:])
+	}
+	return writeConfigFile(filename, buf.Bytes(), perm)
+}
+
+// ListSnippets returns the snippets in the given file. It returns no
+// snippets if the file does not exist.
+func ListSnippets(filename string, opts ...SnippetOption) ([]Snippet, error) {
+	o, err := newSnippetOptions(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, FileError(err, filename)
+	}
+	var snippets []Snippet
+	for _, s := range o.findSnippets(b) {
+		snippets = append(snippets, s.Snippet)
+	}
+	return snippets, nil
+}
+
+// RemoveSnippet removes the snippet with the given name, or the default
+// snippet if name is empty, from the given file. The empty line added before
+// the snippet when it was written is also removed. It does nothing if the
+// file or the snippet do not exist. Modified snippets are handled like in
+// WriteSnippet.
+func RemoveSnippet(filename, name string, opts ...SnippetOption) error {
+	o, err := newSnippetOptions(filename, append(opts, WithSnippetName(name)))
+	if err != nil {
+		return err
+	}
+
+	b, err := readFile(filename)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return FileError(err, filename)
+	}
+
+	s, ok := o.find(b)
+	if !ok {
+		return nil
+	}
+	if err := confirmOverwrite(filename, s); err != nil {
+		return err
+	}
+	start := s.start
+	if start >= 2 && b[start-1] == '\n' && b[start-2] == '\n' {
+		start--
+	}
+
+	perm, err := fileMode(filename, 0600)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(filename, append(b[:start:start], b[s.end:]...), perm)
+}
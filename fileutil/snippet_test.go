@@ -0,0 +1,172 @@
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/cli-utils/ui"
+)
+
+// stripTime removes the timestamps and hashes of the snippet headers.
+func stripTime(b []byte) string {
+	return regexp.MustCompile(` @ \S+( sha256:[0-9a-f]+)?`).ReplaceAllString(string(b), "")
+}
+
+func TestWriteSnippet(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(fn, []byte("Host *\n"), 0600))
+
+	require.NoError(t, WriteSnippet(fn, []byte("default"), 0600))
+	require.NoError(t, WriteSnippet(fn, []byte("foo\n"), 0600, WithSnippetName("foo")))
+	require.NoError(t, WriteSnippet(fn, []byte("bar"), 0600, WithSnippetName("bar")))
+	require.NoError(t, WriteSnippet(fn, []byte("new foo"), 0600, WithSnippetName("foo")))
+	require.NoError(t, WriteSnippet(fn, []byte("new default"), 0600))
+
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, `Host *
+
+# autogenerated by step
+new default
+# end
+
+# autogenerated by step: foo
+new foo
+# end: foo
+
+# autogenerated by step: bar
+bar
+# end: bar
+`, stripTime(b))
+
+	snippets, err := ListSnippets(fn)
+	require.NoError(t, err)
+	assert.Equal(t, []Snippet{
+		{Name: "", Content: []byte("new default\n")},
+		{Name: "foo", Content: []byte("new foo\n")},
+		{Name: "bar", Content: []byte("bar\n")},
+	}, snippets)
+
+	require.NoError(t, RemoveSnippet(fn, "foo"))
+	require.NoError(t, RemoveSnippet(fn, "missing"))
+	require.NoError(t, RemoveSnippet(fn, ""))
+	b, err = os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "Host *\n\n# autogenerated by step: bar\nbar\n# end: bar\n", stripTime(b))
+
+	require.NoError(t, RemoveSnippet(filepath.Join(dir, "missing"), "foo"))
+	snippets, err = ListSnippets(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, snippets)
+
+	assert.Error(t, WriteSnippet(fn, []byte("foo"), 0600, WithSnippetName("foo bar")))
+}
+
+func TestWriteSnippet_legacy(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(fn, []byte(`Host *
+
+# autogenerated by step @ 2020-01-01T00:00:00Z
+old
+# end
+Host foo
+# autogenerated by step: other
+unterminated
+`), 0600))
+
+	require.NoError(t, WriteSnippet(fn, []byte("new"), 0600))
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, `Host *
+
+# autogenerated by step
+new
+# end
+Host foo
+# autogenerated by step: other
+unterminated
+`, stripTime(b))
+}
+
+func TestWriteSnippet_commentPrefix(t *testing.T) {
+	dir := t.TempDir()
+
+	ini := filepath.Join(dir, "config.ini")
+	require.NoError(t, WriteSnippet(ini, []byte("a = b"), 0600, WithSnippetName("foo")))
+	b, err := os.ReadFile(ini)
+	require.NoError(t, err)
+	assert.Equal(t, "; autogenerated by step: foo\na = b\n; end: foo\n", stripTime(b))
+
+	RegisterCommentPrefix(".conf", "//")
+	t.Cleanup(func() { RegisterCommentPrefix(".conf", "") })
+	conf := filepath.Join(dir, "file.conf")
+	require.NoError(t, WriteSnippet(conf, []byte("a"), 0600))
+	require.NoError(t, WriteSnippet(conf, []byte("b"), 0600, WithCommentPrefix("--")))
+	b, err = os.ReadFile(conf)
+	require.NoError(t, err)
+	assert.Equal(t, "// autogenerated by step\na\n// end\n\n-- autogenerated by step\nb\n-- end\n", stripTime(b))
+
+	snippets, err := ListSnippets(conf, WithCommentPrefix("--"))
+	require.NoError(t, err)
+	assert.Equal(t, []Snippet{{Content: []byte("b\n")}}, snippets)
+}
+
+func TestWriteSnippet_modified(t *testing.T) {
+	ui.SetNoPrompt(true)
+	t.Cleanup(func() { ui.SetNoPrompt(false) })
+
+	fn := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, WriteSnippet(fn, []byte("foo"), 0600, WithSnippetName("foo")))
+	require.NoError(t, WriteSnippet(fn, []byte("bar"), 0600, WithSnippetName("bar")))
+
+	// Snippets not modified can be replaced
+	require.NoError(t, WriteSnippet(fn, []byte("new foo"), 0600, WithSnippetName("foo")))
+	snippets, err := ListSnippets(fn)
+	require.NoError(t, err)
+	require.Len(t, snippets, 2)
+	assert.False(t, snippets[0].Modified)
+	assert.False(t, snippets[1].Modified)
+
+	// Edit the content of foo
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	b = bytes.Replace(b, []byte("new foo\n"), []byte("edited foo\n"), 1)
+	require.NoError(t, os.WriteFile(fn, b, 0600))
+
+	snippets, err = ListSnippets(fn)
+	require.NoError(t, err)
+	assert.Equal(t, []Snippet{
+		{Name: "foo", Content: []byte("edited foo\n"), Modified: true},
+		{Name: "bar", Content: []byte("bar\n")},
+	}, snippets)
+
+	err = WriteSnippet(fn, []byte("other"), 0600, WithSnippetName("foo"))
+	assert.True(t, errors.Is(err, ErrSnippetModified))
+	assert.True(t, errors.Is(RemoveSnippet(fn, "foo"), ErrSnippetModified))
+	assert.NoError(t, WriteSnippet(fn, []byte("new bar"), 0600, WithSnippetName("bar")))
+
+	after, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Contains(t, string(after), "edited foo\n")
+
+	// Dry-run mode shows the changes
+	var buf bytes.Buffer
+	SetRecorder(NewRecorder(&buf))
+	err = WriteSnippet(fn, []byte("other"), 0600, WithSnippetName("foo"))
+	SetRecorder(nil)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "-edited foo\n")
+	assert.Contains(t, buf.String(), "+other\n")
+
+	// Legacy headers do not have a hash
+	require.NoError(t, os.WriteFile(fn, []byte("# autogenerated by step @ 2020-01-01T00:00:00Z\nedited\n# end\n"), 0600))
+	require.NoError(t, WriteSnippet(fn, []byte("new"), 0600))
+}
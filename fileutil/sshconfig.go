@@ -0,0 +1,295 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SSHConfigLine is a directive in an OpenSSH client configuration file, like
+// "User admin". Comments and blank lines have an empty Keyword.
+type SSHConfigLine struct {
+	Keyword string
+	Value   string
+	// raw is the original line, it is used to write the lines that have not
+	// been modified as they were.
+	raw string
+}
+
+// NewSSHConfigLine returns a new directive with the given keyword and value.
+func NewSSHConfigLine(keyword, value string) SSHConfigLine {
+	return SSHConfigLine{Keyword: keyword, Value: value}
+}
+
+// IsComment returns true if the line is a comment or a blank line.
+func (l SSHConfigLine) IsComment() bool {
+	return l.Keyword == ""
+}
+
+func (l SSHConfigLine) String() string {
+	if l.raw != "" || l.Keyword == "" {
+		return l.raw
+	}
+	return "    " + l.Keyword + " " + l.Value
+}
+
+// SSHConfigBlock is a Host or Match block in an OpenSSH client configuration
+// file. The directives at the beginning of the file, before the first Host or
+// Match keyword, are in a block with an empty Keyword.
+type SSHConfigBlock struct {
+	// Keyword is "Host" or "Match", or empty for the global block.
+	Keyword string
+	// Value are the patterns or criteria of the block, like "*.example.com".
+	Value string
+	Lines []SSHConfigLine
+	raw   string
+}
+
+// NewSSHConfigBlock returns a new block with the given keyword, "Host" or
+// "Match", value and directives.
+func NewSSHConfigBlock(keyword, value string, lines ...SSHConfigLine) *SSHConfigBlock {
+	return &SSHConfigBlock{
+		Keyword: keyword,
+		Value:   value,
+		Lines:   lines,
+	}
+}
+
+// Get returns the value of the first directive with the given keyword, and
+// true if it exists. Keywords are case insensitive.
+func (b *SSHConfigBlock) Get(keyword string) (string, bool) {
+	for _, l := range b.Lines {
+		if strings.EqualFold(l.Keyword, keyword) {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// is returns true if the block has the given keyword and value.
+func (b *SSHConfigBlock) is(keyword, value string) bool {
+	return strings.EqualFold(b.Keyword, keyword) && b.Value == value
+}
+
+// endsWithBlank returns true if the last line of the block is blank.
+func (b *SSHConfigBlock) endsWithBlank() bool {
+	n := len(b.Lines)
+	return n > 0 && b.Lines[n-1].IsComment() && strings.TrimSpace(b.Lines[n-1].raw) == ""
+}
+
+func (b *SSHConfigBlock) writeTo(w *bytes.Buffer, eol string) {
+	switch {
+	case b.raw != "":
+		w.WriteString(b.raw + eol)
+	case b.Keyword != "":
+		w.WriteString(b.Keyword + " " + b.Value + eol)
+	}
+	for _, l := range b.Lines {
+		w.WriteString(l.String() + eol)
+	}
+}
+
+// SSHConfig is an OpenSSH client configuration file, like ~/.ssh/config.
+type SSHConfig struct {
+	// Blocks are the blocks of the file. The first block is always the global
+	// block, with the directives before the first Host or Match keyword.
+	Blocks []*SSHConfigBlock
+	format lineFormat
+}
+
+// parseSSHConfigLine splits a line in the keyword and the value. The keyword
+// and the value can be separated by spaces or an equal sign.
+func parseSSHConfigLine(raw string) SSHConfigLine {
+	s := strings.TrimSpace(raw)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return SSHConfigLine{raw: raw}
+	}
+	i := strings.IndexAny(s, " \t=")
+	if i < 0 {
+		return SSHConfigLine{Keyword: s, raw: raw}
+	}
+	value := strings.TrimSpace(s[i:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	return SSHConfigLine{Keyword: s[:i], Value: value, raw: raw}
+}
+
+// ParseSSHConfig parses an OpenSSH client configuration. Comments, blank
+// lines, the format of the directives, the line ending and the presence or
+// absence of a trailing newline are preserved.
+func ParseSSHConfig(r io.Reader) (*SSHConfig, error) {
+	current := &SSHConfigBlock{}
+	c := &SSHConfig{Blocks: []*SSHConfigBlock{current}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), sshMaxLineLength)
+	scanner.Split(scanLinesEOL)
+	for scanner.Scan() {
+		l := parseSSHConfigLine(c.format.scan(scanner.Text()))
+		if strings.EqualFold(l.Keyword, "Host") || strings.EqualFold(l.Keyword, "Match") {
+			current = &SSHConfigBlock{Keyword: l.Keyword, Value: l.Value, raw: l.raw}
+			c.Blocks = append(c.Blocks, current)
+			continue
+		}
+		current.Lines = append(current.Lines, l)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "error parsing ssh config")
+	}
+	return c, nil
+}
+
+// Bytes returns the content of the configuration file.
+func (c *SSHConfig) Bytes() []byte {
+	var buf bytes.Buffer
+	eol := c.format.lineEnding()
+	for _, b := range c.Blocks {
+		b.writeTo(&buf, eol)
+	}
+	return c.format.format(buf.Bytes())
+}
+
+func (c *SSHConfig) global() *SSHConfigBlock {
+	if len(c.Blocks) == 0 || c.Blocks[0].Keyword != "" {
+		c.Blocks = append([]*SSHConfigBlock{{}}, c.Blocks...)
+	}
+	return c.Blocks[0]
+}
+
+// Find returns the block with the given keyword and value, like "Host" and
+// "*.example.com", or nil if it does not exist.
+func (c *SSHConfig) Find(keyword, value string) *SSHConfigBlock {
+	for _, b := range c.Blocks {
+		if b.Keyword != "" && b.is(keyword, value) {
+			return b
+		}
+	}
+	return nil
+}
+
+// Set replaces the block with the same keyword and value, or appends it to
+// the end of the file. The comments and blank lines at the end of a replaced
+// block, usually separating it from the next one, are kept.
+func (c *SSHConfig) Set(block *SSHConfigBlock) {
+	c.global()
+	for i, b := range c.Blocks {
+		if b.Keyword != "" && b.is(block.Keyword, block.Value) {
+			n := len(b.Lines)
+			for n > 0 && b.Lines[n-1].IsComment() {
+				n--
+			}
+			block.Lines = append(block.Lines, b.Lines[n:]...)
+			c.Blocks[i] = block
+			return
+		}
+	}
+	last := c.Blocks[len(c.Blocks)-1]
+	if len(last.Lines) > 0 || last.Keyword != "" {
+		if !last.endsWithBlank() {
+			last.Lines = append(last.Lines, SSHConfigLine{})
+		}
+	}
+	c.Blocks = append(c.Blocks, block)
+}
+
+// Remove removes the block with the given keyword and value. It returns false
+// if the block does not exist.
+func (c *SSHConfig) Remove(keyword, value string) bool {
+	for i, b := range c.Blocks {
+		if b.Keyword == "" || !b.is(keyword, value) {
+			continue
+		}
+		c.Blocks = append(c.Blocks[:i], c.Blocks[i+1:]...)
+		// Remove the blank line added before the last block.
+		if i == len(c.Blocks) && i > 0 {
+			if prev := c.Blocks[i-1]; prev.endsWithBlank() {
+				prev.Lines = prev.Lines[:len(prev.Lines)-1]
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Includes returns the values of the global Include directives.
+func (c *SSHConfig) Includes() []string {
+	var includes []string
+	for _, l := range c.global().Lines {
+		if strings.EqualFold(l.Keyword, "Include") {
+			includes = append(includes, l.Value)
+		}
+	}
+	return includes
+}
+
+// AddInclude adds an Include directive with the given value at the beginning
+// of the file, so it applies to all the hosts. It returns false if the
+// directive already exists.
+func (c *SSHConfig) AddInclude(value string) bool {
+	g := c.global()
+	for _, l := range g.Lines {
+		if strings.EqualFold(l.Keyword, "Include") && l.Value == value {
+			return false
+		}
+	}
+	l := SSHConfigLine{Keyword: "Include", Value: value, raw: "Include " + value}
+	g.Lines = append([]SSHConfigLine{l}, g.Lines...)
+	return true
+}
+
+// RemoveInclude removes the global Include directives with the given value.
+// It returns false if the directive does not exist.
+func (c *SSHConfig) RemoveInclude(value string) bool {
+	g := c.global()
+	lines := g.Lines[:0]
+	for _, l := range g.Lines {
+		if !strings.EqualFold(l.Keyword, "Include") || l.Value != value {
+			lines = append(lines, l)
+		}
+	}
+	removed := len(lines) != len(g.Lines)
+	g.Lines = lines
+	return removed
+}
+
+// ReadSSHConfig reads and parses an OpenSSH client configuration file. It
+// returns an empty configuration if the file does not exist.
+func ReadSSHConfig(filename string) (*SSHConfig, error) {
+	b, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, FileError(err, filename)
+	}
+	c, err := ParseSSHConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return c, nil
+}
+
+// EditSSHConfig reads the given OpenSSH client configuration file, calls fn to
+// modify it, and writes it if it has changed. The file is created with 0600
+// permissions if it does not exist.
+func EditSSHConfig(filename string, fn func(*SSHConfig) error) error {
+	old, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return FileError(err, filename)
+	}
+	c, err := ParseSSHConfig(bytes.NewReader(old))
+	if err != nil {
+		return errors.Wrapf(err, "error parsing %s", filename)
+	}
+	if err := fn(c); err != nil {
+		return err
+	}
+	data := c.Bytes()
+	if bytes.Equal(old, data) {
+		return nil
+	}
+	perm, err := fileMode(filename, 0600)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(filename, data, perm)
+}
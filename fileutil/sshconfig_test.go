@@ -0,0 +1,160 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSSHConfig = `# global options
+Include ~/.ssh/other
+ServerAliveInterval=60
+
+Host github.com
+  User git
+	IdentityFile ~/.ssh/github # comment
+
+Match exec "step ssh check-host %h"
+    UserKnownHostsFile = /home/user/.step/ssh/known_hosts
+`
+
+func TestParseSSHConfig(t *testing.T) {
+	c, err := ParseSSHConfig(strings.NewReader(testSSHConfig))
+	require.NoError(t, err)
+	require.Len(t, c.Blocks, 3)
+
+	// Round trip
+	assert.Equal(t, testSSHConfig, string(c.Bytes()))
+
+	assert.Equal(t, []string{"~/.ssh/other"}, c.Includes())
+	v, ok := c.Blocks[0].Get("serveraliveinterval")
+	assert.True(t, ok)
+	assert.Equal(t, "60", v)
+
+	b := c.Find("host", "github.com")
+	require.NotNil(t, b)
+	v, ok = b.Get("IdentityFile")
+	assert.True(t, ok)
+	assert.Equal(t, "~/.ssh/github # comment", v)
+	_, ok = b.Get("Port")
+	assert.False(t, ok)
+
+	b = c.Find("Match", `exec "step ssh check-host %h"`)
+	require.NotNil(t, b)
+	v, _ = b.Get("UserKnownHostsFile")
+	assert.Equal(t, "/home/user/.step/ssh/known_hosts", v)
+	assert.Nil(t, c.Find("Host", "missing"))
+
+	// Long lines
+	long := "# " + strings.Repeat("a", 128*1024) + "\n"
+	c, err = ParseSSHConfig(strings.NewReader(long))
+	require.NoError(t, err)
+	assert.Equal(t, long, string(c.Bytes()))
+}
+
+func TestSSHConfig_edit(t *testing.T) {
+	c, err := ParseSSHConfig(strings.NewReader(testSSHConfig))
+	require.NoError(t, err)
+
+	assert.True(t, c.AddInclude("~/.step/ssh/includes"))
+	assert.False(t, c.AddInclude("~/.step/ssh/includes"))
+	assert.True(t, c.RemoveInclude("~/.ssh/other"))
+	assert.False(t, c.RemoveInclude("~/.ssh/other"))
+
+	c.Set(NewSSHConfigBlock("Host", "github.com", NewSSHConfigLine("User", "admin")))
+	c.Set(NewSSHConfigBlock("Host", "*.example.com", NewSSHConfigLine("Port", "2222")))
+	assert.True(t, c.Remove("Match", `exec "step ssh check-host %h"`))
+	assert.False(t, c.Remove("Host", "missing"))
+
+	assert.Equal(t, `Include ~/.step/ssh/includes
+# global options
+ServerAliveInterval=60
+
+Host github.com
+    User admin
+
+Host *.example.com
+    Port 2222
+`, string(c.Bytes()))
+
+	assert.True(t, c.Remove("Host", "*.example.com"))
+	assert.Equal(t, `Include ~/.step/ssh/includes
+# global options
+ServerAliveInterval=60
+
+Host github.com
+    User admin
+`, string(c.Bytes()))
+}
+
+func TestEditSSHConfig(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "config")
+
+	// No changes do not create the file
+	require.NoError(t, EditSSHConfig(fn, func(c *SSHConfig) error {
+		c.Remove("Host", "foo")
+		return nil
+	}))
+	_, err := os.Stat(fn)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, EditSSHConfig(fn, func(c *SSHConfig) error {
+		c.AddInclude("step")
+		c.Set(NewSSHConfigBlock("Host", "foo", NewSSHConfigLine("User", "bar")))
+		return nil
+	}))
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, "Include step\n\nHost foo\n    User bar\n", string(b))
+
+	c, err := ReadSSHConfig(fn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"step"}, c.Includes())
+	assert.NotNil(t, c.Find("Host", "foo"))
+
+	// Line endings and the missing trailing newline are preserved.
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"crlf", "Host foo\r\n  User bar\r\n", "Host foo\r\n  User bar\r\n\r\nHost baz\r\n    User qux\r\n"},
+		{"no trailing newline", "Host foo\n  User bar", "Host foo\n  User bar\n\nHost baz\n    User qux"},
+		{"crlf no trailing newline", "Host foo\r\n  User bar", "Host foo\r\n  User bar\r\n\r\nHost baz\r\n    User qux"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "config")
+			require.NoError(t, os.WriteFile(fn, []byte(tt.content), 0600))
+			st, err := os.Stat(fn)
+			require.NoError(t, err)
+
+			// No changes do not modify the file
+			require.NoError(t, EditSSHConfig(fn, func(c *SSHConfig) error {
+				c.Remove("Host", "missing")
+				return nil
+			}))
+			st2, err := os.Stat(fn)
+			require.NoError(t, err)
+			assert.True(t, os.SameFile(st, st2))
+
+			require.NoError(t, EditSSHConfig(fn, func(c *SSHConfig) error {
+				c.Set(NewSSHConfigBlock("Host", "baz", NewSSHConfigLine("User", "qux")))
+				return nil
+			}))
+			b, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(b))
+		})
+	}
+}
+
+func TestSSHConfig_zero(t *testing.T) {
+	c := new(SSHConfig)
+	c.Set(NewSSHConfigBlock("Host", "foo", NewSSHConfigLine("User", "bar")))
+	assert.Equal(t, "Host foo\n    User bar\n", string(c.Bytes()))
+}
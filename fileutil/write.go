@@ -1,13 +1,9 @@
 package fileutil
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/pkg/errors"
 
@@ -31,19 +27,33 @@ var (
 	SnippetFooter = "# end"
 )
 
-// WriteFile wraps ioutil.WriteFile with a prompt to overwrite a file if
-// the file exists. It returns ErrFileExists if the user picks to not overwrite
-// the file. If force is set to true, the prompt will not be presented and the
-// file if exists will be overwritten.
-func WriteFile(filename string, data []byte, perm os.FileMode) error {
-	if command.IsForce() {
-		return os.WriteFile(filename, data, perm)
+// WriteFile writes the file atomically. If the file exists, the overwrite
+// policy decides what to do, see OverwritePolicy. By default, the user is
+// asked before overwriting the file, and ErrFileExists is returned if the user
+// picks to not overwrite it or cannot be asked. If force is set to true, the
+// prompt will not be presented and the file if exists will be overwritten. In
+// dry-run mode the prompt is not presented and the change is only recorded.
+//
+// With the OverwriteNew policy, the data is written to a sibling file and a
+// *NotOverwrittenError is returned, so callers do not report the file as
+// updated.
+func WriteFile(filename string, data []byte, perm os.FileMode, opts ...WriteOption) error {
+	o := new(writeOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+	policy := o.policy
+	if policy == "" {
+		var err error
+		if policy, err = ParseOverwritePolicy(command.OverwritePolicy()); err != nil {
+			return err
+		}
 	}
 
-	st, err := os.Stat(filename)
+	st, err := getFS().Stat(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return os.WriteFile(filename, data, perm)
+			return writeFile(filename, data, perm)
 		}
 		return errors.Wrapf(err, "error reading information for %s", filename)
 	}
@@ -52,6 +62,27 @@ func WriteFile(filename string, data []byte, perm os.FileMode) error {
 		return ErrIsDir
 	}
 
+	switch policy {
+	case OverwriteNever:
+		return ErrFileExists
+	case OverwriteAlways:
+		return writeFile(filename, data, perm)
+	case OverwriteBackup:
+		if err := copyToBackup(filename); err != nil {
+			return err
+		}
+		return writeFile(filename, data, perm)
+	case OverwriteNew:
+		return writeNew(filename, data, perm)
+	}
+
+	if IsDryRun() {
+		return writeFile(filename, data, perm)
+	}
+	if !ui.IsInteractive() {
+		return ErrFileExists
+	}
+
 	str, err := ui.Prompt(fmt.Sprintf("Would you like to overwrite %s [y/n]", filename), ui.WithValidateYesNo())
 	if err != nil {
 		return err
@@ -62,91 +93,101 @@ func WriteFile(filename string, data []byte, perm os.FileMode) error {
 		return ErrFileExists
 	}
 
-	return os.WriteFile(filename, data, perm)
+	return writeFile(filename, data, perm)
 }
 
 // AppendNewLine appends the given data at the end of the file. If the last
 // character of the file does not contain an LF it prepends it to the data.
 func AppendNewLine(filename string, data []byte, perm os.FileMode) error {
-	f, err := OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, perm)
+	perm, err := fileMode(filename, perm)
 	if err != nil {
 		return err
 	}
-	// Read last character
-	if st, err := f.File.Stat(); err == nil && st.Size() != 0 {
-		last := make([]byte, 1)
-		f.Seek(-1, 2)
-		f.Read(last)
-		if last[0] != '\n' {
-			f.WriteString("\n")
-		}
+	b, err := readFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return FileError(err, filename)
 	}
-	f.Write(data)
-	return f.Close()
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	return writeConfigFile(filename, append(b, data...), perm)
 }
 
-func writeChunk(filename string, data []byte, hasHeaderFooter bool, header, footer string, perm os.FileMode) error {
-	// Get file permissions
-	if st, err := os.Stat(filename); err == nil {
-		perm = st.Mode()
-	} else if !os.IsNotExist(err) {
-		return FileError(err, filename)
+// fileMode returns the mode of the given file, or perm if the file does not
+// exist.
+func fileMode(filename string, perm os.FileMode) (os.FileMode, error) {
+	st, err := getFS().Stat(filename)
+	switch {
+	case err == nil:
+		return st.Mode(), nil
+	case os.IsNotExist(err):
+		return perm, nil
+	default:
+		return 0, FileError(err, filename)
 	}
+}
 
-	// Read file contents
-	b, err := os.ReadFile(filename)
-	if err != nil && !os.IsNotExist(err) {
-		return FileError(err, filename)
+// readFile reads the given file. In dry-run mode, it returns the content
+// recorded if the file has been modified before.
+func readFile(filename string) ([]byte, error) {
+	if r := getRecorder(); r != nil {
+		if b, ok := r.read(filename); ok {
+			return b, nil
+		}
 	}
+	return getFS().ReadFile(filename)
+}
 
-	// Detect previous configuration
-	_, start, end := findConfiguration(bytes.NewReader(b), header, footer)
-
-	// Replace previous configuration
-	f, err := OpenFile(filename, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, perm)
-	if err != nil {
-		return FileError(err, filename)
-	}
-	if len(b) > 0 {
-		f.Write(b[:start])
-		if start == end {
-			f.WriteString("\n")
+// writeConfigFile is like writeFile, but it creates a backup of the file
+// first, so the edit can be rolled back with Restore. It is used by the
+// functions that edit configuration files, like WriteSnippet or EditFile.
+func writeConfigFile(filename string, data []byte, perm os.FileMode) error {
+	if !IsDryRun() {
+		if err := backupFile(filename, data); err != nil {
+			return errors.Wrapf(err, "error creating backup of %s", filename)
 		}
 	}
-	if !hasHeaderFooter {
-		fmt.Fprintf(f, "%s @ %s\n", header, time.Now().UTC().Format(time.RFC3339))
-	}
-	f.Write(data)
-	if !bytes.HasSuffix(data, []byte("\n")) {
-		f.WriteString("\n")
-	}
-	if !hasHeaderFooter {
-		f.WriteString(footer + "\n")
-	}
-	if len(b) > 0 {
-		f.Write(b[end:])
-	}
-	return f.Close()
+	return writeFile(filename, data, perm)
 }
 
-// WriteSnippet writes the given data into the given filename. It surrounds the
-// data with a default header and footer, and it will replace the previous one.
-func WriteSnippet(filename string, data []byte, perm os.FileMode) error {
-	return writeChunk(filename, data, false, SnippetHeader, SnippetFooter, perm)
+// writeFile atomically replaces the given file with data. In dry-run mode,
+// the change is recorded and the file is not modified. All the write functions
+// in this package use writeFile.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	if r := getRecorder(); r != nil {
+		old, err := readFile(filename)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return FileError(err, filename)
+			}
+			old = nil
+		} else if old == nil {
+			old = []byte{}
+		}
+		return r.record(Change{
+			Filename: filename,
+			Old:      old,
+			New:      data,
+			Perm:     perm,
+		})
+	}
+	return writeFileAtomic(filename, data, perm)
 }
 
 // PrependLine prepends the given line into the given filename and removes
 // other instances of the line in the file.
+//
+// Deprecated: PrependLine removes the blank lines and the trailing newline of
+// the file. Use EditFile with RemoveLines and PrependLines.
 func PrependLine(filename string, data []byte, perm os.FileMode) error {
 	// Get file permissions
-	if st, err := os.Stat(filename); err == nil {
-		perm = st.Mode()
-	} else if !os.IsNotExist(err) {
-		return FileError(err, filename)
+	perm, err := fileMode(filename, perm)
+	if err != nil {
+		return err
 	}
 
 	// Read file contents
-	b, err := os.ReadFile(filename)
+	b, err := readFile(filename)
 	if err != nil && !os.IsNotExist(err) {
 		return FileError(err, filename)
 	}
@@ -159,31 +200,28 @@ func PrependLine(filename string, data []byte, perm os.FileMode) error {
 		}
 	}
 
-	if err := os.WriteFile(filename, []byte(strings.Join(result, "\n")), perm); err != nil { // #nosec G703 -- intentional write to user-provided filename
-		return FileError(err, filename)
-	}
-	return nil
+	return writeConfigFile(filename, []byte(strings.Join(result, "\n")), perm)
 }
 
 // RemoveLine removes a single line which contains the given substring from the
 // given file.
+//
+// Deprecated: RemoveLine only removes the first match. Use EditFile with
+// RemoveLines or RemoveFirstLine.
 func RemoveLine(filename, substr string) error {
-	var perm os.FileMode
-	// Get file permissions
-	st, err := os.Stat(filename)
+	// Read file contents
+	b, err := readFile(filename)
 	switch {
 	case os.IsNotExist(err):
 		return nil
 	case err != nil:
 		return FileError(err, filename)
-	default:
-		perm = st.Mode()
 	}
 
-	// Read file contents
-	b, err := os.ReadFile(filename)
-	if err != nil && !os.IsNotExist(err) {
-		return FileError(err, filename)
+	// Get file permissions
+	perm, err := fileMode(filename, 0600)
+	if err != nil {
+		return err
 	}
 
 	old := strings.Split(string(b), "\n")
@@ -191,42 +229,8 @@ func RemoveLine(filename, substr string) error {
 		if !strings.Contains(l, substr) {
 			continue
 		}
-		if err := os.WriteFile(filename, []byte(strings.Join(append(old[:i], old[i+1:]...), "\n")), perm); err != nil { // #nosec G703 -- intentional write to user-provided filename
-			return FileError(err, filename)
-		}
-		break
+		return writeConfigFile(filename, []byte(strings.Join(append(old[:i], old[i+1:]...), "\n")), perm)
 	}
 
 	return nil
 }
-
-type offsetCounter struct {
-	offset int64
-}
-
-func (o *offsetCounter) ScanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	advance, token, err = bufio.ScanLines(data, atEOF)
-	o.offset += int64(advance)
-	return
-}
-
-func findConfiguration(r io.Reader, header, footer string) (lines []string, start, end int64) {
-	var inConfig bool
-	counter := new(offsetCounter)
-	scanner := bufio.NewScanner(r)
-	scanner.Split(counter.ScanLines)
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch {
-		case !inConfig && strings.HasPrefix(line, header):
-			inConfig = true
-			start = counter.offset - int64(len(line)+1)
-		case inConfig && strings.HasPrefix(line, footer):
-			return lines, start, counter.offset
-		case inConfig:
-			lines = append(lines, line)
-		}
-	}
-
-	return lines, counter.offset, counter.offset
-}
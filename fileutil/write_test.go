@@ -0,0 +1,166 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/command"
+	"github.com/smallstep/cli-utils/ui"
+)
+
+func TestWriteFile_overwritePolicy(t *testing.T) {
+	ui.SetNoPrompt(true)
+	t.Cleanup(func() { ui.SetNoPrompt(false) })
+
+	readFile := func(t *testing.T, fn string) string {
+		t.Helper()
+		b, err := os.ReadFile(fn)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []WriteOption
+		env     string
+		wantErr error
+		want    map[string]string
+	}{
+		{"prompt", nil, "", ErrFileExists, map[string]string{"file": "old"}},
+		{"never", []WriteOption{WithOverwritePolicy(OverwriteNever)}, "", ErrFileExists, map[string]string{"file": "old"}},
+		{"overwrite", []WriteOption{WithOverwritePolicy(OverwriteAlways)}, "", nil, map[string]string{"file": "new"}},
+		{"backup", []WriteOption{WithOverwritePolicy(OverwriteBackup)}, "", nil, map[string]string{"file": "new", "file.bak": "old"}},
+		{"new", []WriteOption{WithOverwritePolicy(OverwriteNew)}, "", ErrFileExists, map[string]string{"file": "old", "file.new": "new"}},
+		{"env", nil, "overwrite", nil, map[string]string{"file": "new"}},
+		{"option over env", []WriteOption{WithOverwritePolicy(OverwriteNever)}, "overwrite", ErrFileExists, map[string]string{"file": "old"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(command.OverwriteEnv, tt.env)
+			dir := t.TempDir()
+			fn := filepath.Join(dir, "file")
+			require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+
+			err := WriteFile(fn, []byte("new"), 0600, tt.opts...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, len(tt.want))
+			for name, content := range tt.want {
+				assert.Equal(t, content, readFile(t, filepath.Join(dir, name)))
+			}
+		})
+	}
+
+	t.Run("backup rotation", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "file")
+		for _, s := range []string{"1", "2", "3"} {
+			require.NoError(t, WriteFile(fn, []byte(s), 0600, WithOverwritePolicy(OverwriteBackup)))
+		}
+		assert.Equal(t, "3", readFile(t, fn))
+		assert.Equal(t, "1", readFile(t, fn+".bak"))
+		assert.Equal(t, "2", readFile(t, fn+".bak.1"))
+	})
+
+	t.Run("new rotation", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+		for i, s := range []string{"1", "2"} {
+			err := WriteFile(fn, []byte(s), 0600, WithOverwritePolicy(OverwriteNew))
+			var ne *NotOverwrittenError
+			require.ErrorAs(t, err, &ne)
+			assert.Equal(t, fn, ne.Filename)
+			assert.Equal(t, []string{fn + ".new", fn + ".new.1"}[i], ne.Path)
+		}
+		assert.Equal(t, "old", readFile(t, fn))
+		assert.Equal(t, "1", readFile(t, fn+".new"))
+		assert.Equal(t, "2", readFile(t, fn+".new.1"))
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, WriteFile(fn, []byte("new"), 0600, WithOverwritePolicy(OverwriteNever)))
+		assert.Equal(t, "new", readFile(t, fn))
+	})
+
+	t.Run("force over env", func(t *testing.T) {
+		t.Setenv(command.OverwriteEnv, "never")
+		run := func(args ...string) map[string]string {
+			dir := t.TempDir()
+			fn := filepath.Join(dir, "file")
+			require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+			app := cli.NewApp()
+			app.Flags = []cli.Flag{cli.BoolFlag{Name: "force"}, command.OverwriteFlag}
+			app.Action = command.ActionFunc(func(*cli.Context) error {
+				return WriteFile(fn, []byte("new"), 0600)
+			})
+			require.NoError(t, app.Run(append([]string{"step"}, args...)))
+			got := make(map[string]string)
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			for _, e := range entries {
+				got[e.Name()] = readFile(t, filepath.Join(dir, e.Name()))
+			}
+			return got
+		}
+		t.Cleanup(func() {
+			// Reset the current context.
+			app := cli.NewApp()
+			app.Action = command.ActionFunc(func(*cli.Context) error { return nil })
+			app.Run([]string{"step"})
+		})
+
+		assert.Equal(t, map[string]string{"file": "new"}, run("--force"))
+		assert.Equal(t, map[string]string{"file": "new", "file.bak": "old"}, run("--force", "--overwrite", "backup"))
+	})
+
+	t.Run("new concurrent", func(t *testing.T) {
+		fn := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(fn, []byte("old"), 0600))
+		var wg sync.WaitGroup
+		paths := make([]string, 10)
+		for i := range paths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var ne *NotOverwrittenError
+				if assert.ErrorAs(t, WriteFile(fn, []byte(strconv.Itoa(i)), 0600, WithOverwritePolicy(OverwriteNew)), &ne) {
+					paths[i] = ne.Path
+				}
+			}()
+		}
+		wg.Wait()
+		for i, p := range paths {
+			assert.Equal(t, strconv.Itoa(i), readFile(t, p))
+		}
+	})
+
+	t.Run("invalid env", func(t *testing.T) {
+		t.Setenv(command.OverwriteEnv, "sometimes")
+		assert.Error(t, WriteFile(filepath.Join(t.TempDir(), "file"), []byte("new"), 0600))
+	})
+}
+
+func TestParseOverwritePolicy(t *testing.T) {
+	for s, want := range map[string]OverwritePolicy{
+		"": OverwritePrompt, "prompt": OverwritePrompt, "NEVER": OverwriteNever,
+		"overwrite": OverwriteAlways, " backup ": OverwriteBackup, "new": OverwriteNew,
+	} {
+		got, err := ParseOverwritePolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseOverwritePolicy("always")
+	assert.Error(t, err)
+}
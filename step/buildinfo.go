@@ -0,0 +1,96 @@
+package step
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// readBuildInfo is the function used to read the build information embedded in
+// the binary. The information does not change, so it is read only once. It can
+// be replaced in tests.
+var readBuildInfo = sync.OnceValues(debug.ReadBuildInfo)
+
+// Module is a module dependency of the binary.
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// BuildInfo contains the version and build information of the binary.
+type BuildInfo struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	ReleaseDate  string   `json:"releaseDate"`
+	GoVersion    string   `json:"goVersion"`
+	OS           string   `json:"os"`
+	Arch         string   `json:"arch"`
+	Revision     string   `json:"revision,omitempty"`
+	RevisionTime string   `json:"revisionTime,omitempty"`
+	Dirty        bool     `json:"dirty"`
+	Dependencies []Module `json:"dependencies,omitempty"`
+}
+
+// ShortRevision returns the first 7 characters of the VCS revision, with the
+// suffix "-dirty" if the working tree had local modifications.
+func (b *BuildInfo) ShortRevision() string {
+	rev := b.Revision
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	if rev != "" && b.Dirty {
+		rev += "-dirty"
+	}
+	return rev
+}
+
+// Info returns the version and build information of the binary. The name,
+// version and release date are the ones set with Set, the VCS and module
+// information is read from the information embedded by the Go toolchain.
+// Development builds, without a version set, use the VCS revision as version.
+func Info() *BuildInfo {
+	info := &BuildInfo{
+		Name:        name,
+		Version:     version,
+		ReleaseDate: ReleaseDate(),
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+	}
+
+	if bi, ok := readBuildInfo(); ok {
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.time":
+				info.RevisionTime = s.Value
+			case "vcs.modified":
+				info.Dirty, _ = strconv.ParseBool(s.Value)
+			}
+		}
+		for _, dep := range bi.Deps {
+			m := Module{Path: dep.Path, Version: dep.Version}
+			if dep.Replace != nil {
+				m.Replace = dep.Replace.Path
+				if dep.Replace.Version != "" {
+					m.Replace += "@" + dep.Replace.Version
+				}
+			}
+			info.Dependencies = append(info.Dependencies, m)
+		}
+	}
+
+	if info.Version == "N/A" {
+		info.Version = "0000000-dev"
+		if info.Revision != "" {
+			info.Version = info.ShortRevision() + "-dev"
+		}
+	}
+	return info
+}
@@ -0,0 +1,59 @@
+package step
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo(t *testing.T) {
+	oldRead, oldName, oldVersion, oldBuildTime := readBuildInfo, name, version, buildTime
+	t.Cleanup(func() {
+		readBuildInfo, name, version, buildTime = oldRead, oldName, oldVersion, oldBuildTime
+	})
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.99.0",
+			Deps: []*debug.Module{
+				{Path: "github.com/urfave/cli", Version: "v1.22.17"},
+				{Path: "go.step.sm/crypto", Version: "v0.87.0", Replace: &debug.Module{Path: "../crypto"}},
+			},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "0123456789abcdef"},
+				{Key: "vcs.time", Value: "2026-10-18T12:00:00Z"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, true
+	}
+
+	Set("Smallstep CLI", "N/A", "2026-10-18 12:00 UTC")
+	assert.Equal(t, &BuildInfo{
+		Name:         "Smallstep CLI",
+		Version:      "0123456-dirty-dev",
+		ReleaseDate:  "2026-10-18 12:00 UTC",
+		GoVersion:    "go1.99.0",
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Revision:     "0123456789abcdef",
+		RevisionTime: "2026-10-18T12:00:00Z",
+		Dirty:        true,
+		Dependencies: []Module{
+			{Path: "github.com/urfave/cli", Version: "v1.22.17"},
+			{Path: "go.step.sm/crypto", Version: "v0.87.0", Replace: "../crypto"},
+		},
+	}, Info())
+	assert.Equal(t, "Smallstep CLI/0123456-dirty-dev ("+runtime.GOOS+"/"+runtime.GOARCH+")", Version())
+
+	Set("Smallstep CLI", "0.28.0", "2026-10-18 12:00 UTC")
+	assert.Equal(t, "Smallstep CLI/0.28.0 ("+runtime.GOOS+"/"+runtime.GOARCH+")", Version())
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	Set("Smallstep CLI", "N/A", "2026-10-18 12:00 UTC")
+	info := Info()
+	assert.Equal(t, "0000000-dev", info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Empty(t, info.ShortRevision())
+}
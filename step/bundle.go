@@ -0,0 +1,442 @@
+package step
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/errs"
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+const (
+	// bundleVersion is the version of the context bundle format.
+	bundleVersion = 1
+	// bundleManifestName is the name of the file in a context bundle with the
+	// context definition.
+	bundleManifestName = "context.json"
+	// maxBundleSize is the maximum size of the uncompressed contents of a
+	// context bundle.
+	maxBundleSize = 10 << 20
+)
+
+// bundleManifest is the context definition stored in a context bundle.
+type bundleManifest struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Profile   string `json:"profile"`
+	Authority string `json:"authority"`
+}
+
+// bundleSource is a directory included in a context bundle. The files in the
+// subdirectories dirs of base are stored with the given prefix.
+type bundleSource struct {
+	prefix string
+	base   string
+	dirs   []string
+}
+
+// bundleSources returns the directories included in a context bundle. Secrets
+// and identities are never exported.
+func bundleSources(c *Context) []bundleSource {
+	return []bundleSource{
+		{prefix: "authority", base: c.Path(), dirs: []string{"config", "certs"}},
+		{prefix: "profile", base: c.ProfilePath(), dirs: []string{"config"}},
+	}
+}
+
+// Export writes the context with the given name to w as a gzipped tar archive.
+// The archive contains the context definition, the authority configuration
+// and certificates, and the profile configuration. Paths in the authority
+// defaults file that point inside the authority directory are made relative,
+// so they are valid wherever the bundle is imported. If the profile has parent
+// profiles, the defaults of the profile chain are merged into the exported
+// profile defaults, and the parent is removed from the profile file.
+func (cs *CtxState) Export(name string, w io.Writer) error {
+	c, ok := cs.Get(name)
+	if !ok {
+		return errors.Errorf("context '%s' not found", name)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	b, err := json.MarshalIndent(bundleManifest{
+		Version:   bundleVersion,
+		Name:      c.Name,
+		Profile:   c.Profile,
+		Authority: c.Authority,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := writeBundleEntry(tw, bundleManifestName, b, 0600); err != nil {
+		return err
+	}
+
+	profileFiles, err := c.ProfileDefaultsFiles()
+	if err != nil {
+		return errors.Wrapf(err, "error exporting context '%s'", name)
+	}
+	flatten := len(profileFiles) > 1
+
+	efs := cs.getEnv().FS()
+	for _, src := range bundleSources(c) {
+		for _, dir := range src.dirs {
+			root := filepath.Join(src.base, dir)
+			err := fs.WalkDir(fsys.DirFS(efs, root), ".", func(p string, d fs.DirEntry, err error) error {
+				fn := filepath.Join(root, filepath.FromSlash(p))
+				switch {
+				case err != nil && p == "." && errors.Is(err, fs.ErrNotExist):
+					return nil
+				case err != nil:
+					return errs.FileError(err, fn)
+				case !d.Type().IsRegular():
+					return nil
+				}
+
+				st, err := d.Info()
+				if err != nil {
+					return errs.FileError(err, fn)
+				}
+				if flatten && fn == c.ProfileDefaultsFile() {
+					// Written below with the defaults of the parents.
+					return nil
+				}
+				b, err := efs.ReadFile(fn)
+				if err != nil {
+					return errs.FileError(err, fn)
+				}
+				switch {
+				case fn == c.DefaultsFile():
+					if b, err = relativizeDefaults(b, src.base); err != nil {
+						return errors.Wrapf(err, "error parsing %s", fn)
+					}
+				case flatten && fn == c.ProfileFile():
+					if b, err = removeParent(b); err != nil {
+						return errors.Wrapf(err, "error parsing %s", fn)
+					} else if b == nil {
+						return nil
+					}
+				}
+
+				rel, err := filepath.Rel(src.base, fn)
+				if err != nil {
+					return err
+				}
+				return writeBundleEntry(tw, path.Join(src.prefix, filepath.ToSlash(rel)), b, st.Mode().Perm())
+			})
+			if err != nil {
+				return errors.Wrapf(err, "error exporting context '%s'", name)
+			}
+		}
+	}
+
+	if flatten {
+		values, err := mergeDefaults(cs.getEnv(), profileFiles)
+		if err != nil {
+			return errors.Wrapf(err, "error exporting context '%s'", name)
+		}
+		if len(values) > 0 {
+			b, err := json.MarshalIndent(values, "", "    ")
+			if err != nil {
+				return err
+			}
+			if err := writeBundleEntry(tw, "profile/config/defaults.json", b, 0600); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "error writing context bundle")
+	}
+	return errors.Wrap(gw.Close(), "error writing context bundle")
+}
+
+func writeBundleEntry(tw *tar.Writer, name string, b []byte, perm os.FileMode) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(perm),
+		Size:     int64(len(b)),
+	}); err != nil {
+		return errors.Wrap(err, "error writing context bundle")
+	}
+	_, err := tw.Write(b)
+	return errors.Wrap(err, "error writing context bundle")
+}
+
+// relativizeDefaults converts the string values in a defaults file pointing
+// inside base into paths relative to base.
+func relativizeDefaults(b []byte, base string) ([]byte, error) {
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	prefix := filepath.Clean(base) + string(filepath.Separator)
+	for k, v := range values {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, prefix) {
+			values[k] = filepath.ToSlash(strings.TrimPrefix(s, prefix))
+		}
+	}
+	return json.MarshalIndent(values, "", "    ")
+}
+
+// removeParent removes the parent from a profile file. It returns nil if the
+// profile file has no other values.
+func removeParent(b []byte) ([]byte, error) {
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	delete(values, "parent")
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return json.MarshalIndent(values, "", "    ")
+}
+
+type importOptions struct {
+	name            string
+	overwrite       bool
+	valueReferences bool
+}
+
+// ImportOption is the type of the functions that modify the import options.
+type ImportOption func(*importOptions)
+
+// WithImportName sets the name of the imported context instead of the one in
+// the bundle.
+func WithImportName(name string) ImportOption {
+	return func(o *importOptions) {
+		o.name = name
+	}
+}
+
+// WithImportOverwrite, if true, replaces an existing context, authority or
+// profile with the same name. By default, a numeric suffix is added to the
+// names that already exist.
+func WithImportOverwrite(b bool) ImportOption {
+	return func(o *importOptions) {
+		o.overwrite = b
+	}
+}
+
+// WithImportValueReferences, if true, allows file:, env: and exec: references
+// in the configuration files of the bundle, see IsValueReference. By default,
+// a bundle with references is rejected, because they would read local files or
+// run commands when the imported context is used.
+func WithImportValueReferences(b bool) ImportOption {
+	return func(o *importOptions) {
+		o.valueReferences = b
+	}
+}
+
+type bundleEntry struct {
+	name string
+	data []byte
+	perm os.FileMode
+}
+
+// Import reads a context bundle created by Export, validates it, writes the
+// authority and profile files, and adds the new context to the context map.
+// Nothing is written if the bundle is not valid. It returns the imported
+// context; its name, authority and profile might differ from the ones in the
+// bundle if they already exist.
+func (cs *CtxState) Import(r io.Reader, opts ...ImportOption) (*Context, error) {
+	o := new(importOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	manifest, entries, err := readBundle(r, o.valueReferences)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Context{
+		Name:      manifest.Name,
+		Profile:   manifest.Profile,
+		Authority: manifest.Authority,
+		env:       cs.getEnv(),
+	}
+	if o.name != "" {
+		c.Name = o.name
+	}
+	if c.Name == "" {
+		return nil, errors.New("error importing context: context name cannot be empty")
+	}
+	if err := c.Validate(); err != nil {
+		return nil, errors.Wrap(err, "error importing context")
+	}
+	for _, name := range []string{c.Name, c.Authority, c.Profile} {
+		if name != filepath.Base(name) || name == "." || name == ".." {
+			return nil, errors.Errorf("error importing context: invalid name '%s'", name)
+		}
+	}
+
+	// Resolve conflicts with existing contexts, and with existing authority
+	// and profile directories that would be modified by the import.
+	if !o.overwrite {
+		var hasAuthority, hasProfile bool
+		for _, e := range entries {
+			hasAuthority = hasAuthority || strings.HasPrefix(e.name, "authority/")
+			hasProfile = hasProfile || strings.HasPrefix(e.name, "profile/")
+		}
+		c.Name = freeName(c.Name, func(s string) bool {
+			_, ok := cs.Get(s)
+			return ok
+		})
+		if hasAuthority {
+			c.Authority = freeName(c.Authority, func(s string) bool {
+				return cs.getEnv().exists(filepath.Join(cs.getEnv().BasePath(), "authorities", s))
+			})
+		}
+		if hasProfile {
+			c.Profile = freeName(c.Profile, func(s string) bool {
+				return cs.getEnv().exists(filepath.Join(cs.getEnv().BasePath(), "profiles", s))
+			})
+		}
+	}
+
+	for _, e := range entries {
+		var fn string
+		if rel, ok := strings.CutPrefix(e.name, "authority/"); ok {
+			fn = filepath.Join(c.Path(), filepath.FromSlash(rel))
+		} else {
+			rel, _ = strings.CutPrefix(e.name, "profile/")
+			fn = filepath.Join(c.ProfilePath(), filepath.FromSlash(rel))
+		}
+		if err := cs.getEnv().FS().MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			return nil, errs.FileError(err, fn)
+		}
+		if err := writeFileAtomic(cs.getEnv(), fn, e.data, e.perm); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cs.Add(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readBundle reads and validates the manifest and files in a context bundle.
+// Value references in configuration files are rejected unless allowRefs is
+// true.
+func readBundle(r io.Reader, allowRefs bool) (*bundleManifest, []bundleEntry, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error reading context bundle")
+	}
+	defer gr.Close()
+
+	var (
+		manifest *bundleManifest
+		entries  []bundleEntry
+		size     int64
+	)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, errors.Wrap(err, "error reading context bundle")
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, nil, errors.Errorf("error reading context bundle: '%s' is not a regular file", hdr.Name)
+		}
+
+		if size += hdr.Size; size > maxBundleSize {
+			return nil, nil, errors.New("error reading context bundle: bundle is too large")
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(tr, hdr.Size)); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading context bundle")
+		}
+
+		if hdr.Name == bundleManifestName {
+			manifest = new(bundleManifest)
+			if err := json.Unmarshal(buf.Bytes(), manifest); err != nil {
+				return nil, nil, errors.Wrapf(err, "error parsing %s", bundleManifestName)
+			}
+			continue
+		}
+
+		if err := validateBundleEntry(hdr.Name, buf.Bytes(), allowRefs); err != nil {
+			return nil, nil, errors.Wrap(err, "error reading context bundle")
+		}
+		entries = append(entries, bundleEntry{
+			name: hdr.Name,
+			data: buf.Bytes(),
+			perm: hdr.FileInfo().Mode().Perm() & 0644,
+		})
+	}
+
+	switch {
+	case manifest == nil:
+		return nil, nil, errors.Errorf("error reading context bundle: %s not found", bundleManifestName)
+	case manifest.Version != bundleVersion:
+		return nil, nil, errors.Errorf("error reading context bundle: unsupported version %d", manifest.Version)
+	}
+	return manifest, entries, nil
+}
+
+// validateBundleEntry checks that the name of a file in a bundle is a clean
+// path inside the authority or profile directories, and that configuration
+// files are valid JSON without value references, unless allowRefs is true.
+func validateBundleEntry(name string, data []byte, allowRefs bool) error {
+	if name != path.Clean(name) || path.IsAbs(name) || strings.Contains(name, `\`) ||
+		(!strings.HasPrefix(name, "authority/") && !strings.HasPrefix(name, "profile/")) {
+		return errors.Errorf("invalid file name '%s'", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return errors.Errorf("invalid file name '%s'", name)
+		}
+	}
+	if path.Ext(name) == ".json" {
+		values := make(map[string]interface{})
+		if err := json.Unmarshal(data, &values); err != nil {
+			return errors.Wrapf(err, "error parsing %s", name)
+		}
+		if !allowRefs {
+			for k, v := range values {
+				if IsValueReference(v) {
+					return errors.Errorf("%s: value of '%s' is a reference to a file, environment variable or command", name, k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// freeName returns name if it's not taken, or the first name with a numeric
+// suffix that is not taken.
+func freeName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	for i := 1; ; i++ {
+		if s := fmt.Sprintf("%s-%d", name, i); !taken(s) {
+			return s
+		}
+	}
+}
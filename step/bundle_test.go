@@ -0,0 +1,147 @@
+package step
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCtxState_ExportImport(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	c := &Context{Name: "ca.local", Authority: "ca.local", Profile: "ca.local"}
+	writeFile := func(fn, data string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0700))
+		require.NoError(t, os.WriteFile(fn, []byte(data), 0600))
+	}
+	root := filepath.Join(c.Path(), "certs", "root_ca.crt")
+	writeFile(c.DefaultsFile(), `{"ca-url":"https://ca.local","root":"`+filepath.ToSlash(root)+`"}`)
+	writeFile(root, "root")
+	writeFile(filepath.Join(c.Path(), "secrets", "root_ca_key"), "secret")
+	writeFile(c.ProfileDefaultsFile(), `{"not-after":"24h"}`)
+
+	cs := &CtxState{}
+	require.NoError(t, cs.Add(c))
+
+	var buf bytes.Buffer
+	require.NoError(t, cs.Export("ca.local", &buf))
+	assert.EqualError(t, cs.Export("missing", &buf), "context 'missing' not found")
+
+	// Import with conflicts
+	imported, err := cs.Import(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, &Context{Name: "ca.local-1", Authority: "ca.local-1", Profile: "ca.local-1", env: defaultEnv}, imported)
+	got, ok := cs.Get("ca.local-1")
+	require.True(t, ok)
+	assert.Equal(t, imported, got)
+
+	b, err := os.ReadFile(imported.DefaultsFile())
+	require.NoError(t, err)
+	var defaults map[string]any
+	require.NoError(t, json.Unmarshal(b, &defaults))
+	assert.Equal(t, map[string]any{"ca-url": "https://ca.local", "root": "certs/root_ca.crt"}, defaults)
+	assert.FileExists(t, filepath.Join(imported.Path(), "certs", "root_ca.crt"))
+	assert.FileExists(t, imported.ProfileDefaultsFile())
+	assert.NoFileExists(t, filepath.Join(imported.Path(), "secrets", "root_ca_key"))
+
+	// Import with a new name
+	imported, err = cs.Import(bytes.NewReader(buf.Bytes()), WithImportName("other"))
+	require.NoError(t, err)
+	assert.Equal(t, &Context{Name: "other", Authority: "ca.local-2", Profile: "ca.local-2", env: defaultEnv}, imported)
+
+	// Import overwriting the existing context
+	imported, err = cs.Import(bytes.NewReader(buf.Bytes()), WithImportOverwrite(true))
+	require.NoError(t, err)
+	assert.Equal(t, &Context{Name: "ca.local", Authority: "ca.local", Profile: "ca.local", env: defaultEnv}, imported)
+
+	// Value references are only imported if they are allowed
+	writeFile(c.ProfileDefaultsFile(), `{"password":"file:/etc/password"}`)
+	buf.Reset()
+	require.NoError(t, cs.Export("ca.local", &buf))
+	_, err = cs.Import(bytes.NewReader(buf.Bytes()), WithImportName("refs"))
+	assert.ErrorContains(t, err, "value of 'password' is a reference")
+	imported, err = cs.Import(bytes.NewReader(buf.Bytes()), WithImportName("refs"), WithImportValueReferences(true))
+	require.NoError(t, err)
+	assert.Equal(t, "refs", imported.Name)
+}
+
+func TestCtxState_Export_profileChain(t *testing.T) {
+	t.Setenv(PathEnv, "")
+	home := t.TempDir()
+	src, dst := NewEnv(home, filepath.Join(home, "src")), NewEnv(home, filepath.Join(home, "dst"))
+	require.NoError(t, src.Init())
+	require.NoError(t, dst.Init())
+
+	writeFile := func(fn, data string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0700))
+		require.NoError(t, os.WriteFile(fn, []byte(data), 0600))
+	}
+	profileDir := func(name string) string {
+		return filepath.Join(src.BasePath(), "profiles", name, "config")
+	}
+	writeFile(filepath.Join(profileDir("team"), "defaults.json"), `{"not-after":"24h","provisioner":"team"}`)
+	writeFile(filepath.Join(profileDir("me"), "defaults.json"), `{"provisioner":"me@example.com"}`)
+	writeFile(filepath.Join(profileDir("me"), "profile.json"), `{"parent":"team"}`)
+	require.NoError(t, src.Contexts().Add(&Context{Name: "ca", Authority: "ca", Profile: "me"}))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Contexts().Export("ca", &buf))
+	imported, err := dst.Contexts().Import(&buf)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, imported.ProfileFile())
+	require.NoError(t, dst.Contexts().SetCurrent("ca"))
+	config, err := dst.Contexts().GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"not-after": "24h", "provisioner": "me@example.com"}, config)
+}
+
+func TestCtxState_Import_invalid(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	bundle := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for name, data := range files {
+			require.NoError(t, writeBundleEntry(tw, name, []byte(data), 0600))
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, gw.Close())
+		return buf.Bytes()
+	}
+	manifest := `{"version":1,"name":"ca","authority":"ca","profile":"ca"}`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"no-manifest", map[string]string{}, "error reading context bundle: context.json not found"},
+		{"bad-version", map[string]string{"context.json": `{"version":2}`}, "error reading context bundle: unsupported version 2"},
+		{"no-authority", map[string]string{"context.json": `{"version":1,"name":"ca","profile":"ca"}`}, "error importing context: context cannot have an empty authority value"},
+		{"bad-name", map[string]string{"context.json": `{"version":1,"name":"../ca","authority":"ca","profile":"ca"}`}, "error importing context: invalid name '../ca'"},
+		{"zip-slip", map[string]string{"context.json": manifest, "authority/../../evil": "evil"}, "error reading context bundle: invalid file name 'authority/../../evil'"},
+		{"unknown-dir", map[string]string{"context.json": manifest, "secrets/key": "key"}, "error reading context bundle: invalid file name 'secrets/key'"},
+		{"bad-json", map[string]string{"context.json": manifest, "authority/config/defaults.json": "{"}, "error reading context bundle: error parsing authority/config/defaults.json"},
+		{"value-reference", map[string]string{"context.json": manifest, "authority/config/defaults.json": `{"ca-url":"exec:sh -c evil"}`}, "error reading context bundle: authority/config/defaults.json: value of 'ca-url' is a reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &CtxState{}
+			_, err := cs.Import(bytes.NewReader(bundle(tt.files)))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			assert.NoDirExists(t, filepath.Join(BasePath(), "authorities"))
+			assert.NoFileExists(t, ContextsFile())
+		})
+	}
+}
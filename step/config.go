@@ -9,6 +9,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
 )
 
 // PathEnv defines the name of the environment variable that can overwrite
@@ -19,6 +21,10 @@ const PathEnv = "STEPPATH"
 // default home directory.
 const HomeEnv = "HOME"
 
+// ContextEnv defines the name of the environment variable that can overwrite
+// the current context without modifying the current context file.
+const ContextEnv = "STEP_CONTEXT"
+
 var (
 	// version and buildTime are filled in during build by the Makefile
 	name      = "Smallstep CLI"
@@ -26,63 +32,273 @@ var (
 	version   = "N/A"
 )
 
-var cache struct {
-	sync.Once
+// Env is a step environment. It contains the home directory, the base step
+// path, the file system and the context state used to resolve the step paths.
+// Most programs will use the default environment through the package
+// functions, but a program can create multiple environments with different
+// step paths.
+type Env struct {
+	once         sync.Once
 	err          error
-	stepBasePath string // stepBasePath will be populated in init() with the proper STEPPATH.
-	homePath     string // homePath will be populated in init() with the proper HOME.
+	homePath     string
+	stepBasePath string
+	statePath    string
+	cachePath    string
+	fs           fsys.FS
+	contexts     *CtxState
 }
 
-// Init initializes the step environment.
-//
-// A program calling this function should fail because the step variables would
-// be undefined.
-func Init() error {
-	if err := initStepPath(); err != nil {
+// defaultEnv is the environment used by the package functions. Its paths are
+// initialized from the environment variables on first use.
+var defaultEnv = NewEnv("", "")
+
+// NewEnv creates a new step environment with the given home directory and
+// base step path. If homePath is empty, it will be initialized using the
+// environment variable HOME or the os/user package. If stepBasePath is empty,
+// it will be initialized using the environment variable STEPPATH, the XDG base
+// directories if they are used, see XDGPaths, or $HOME/.step.
+func NewEnv(homePath, stepBasePath string) *Env {
+	e := &Env{
+		homePath:     homePath,
+		stepBasePath: stepBasePath,
+	}
+	e.contexts = &CtxState{env: e}
+	return e
+}
+
+// DefaultEnv returns the environment used by the package functions.
+func DefaultEnv() *Env {
+	return defaultEnv
+}
+
+// SetFS sets the file system used to read and write the configuration of the
+// environment, like the contexts and defaults files. By default, the file
+// system of the operating system is used. It must be called before the
+// environment is used.
+func (e *Env) SetFS(f fsys.FS) {
+	e.fs = f
+}
+
+// FS returns the file system used by the environment.
+func (e *Env) FS() fsys.FS {
+	if e.fs == nil {
+		return fsys.OS
+	}
+	return e.fs
+}
+
+// exists returns true if the named file exists in the file system of the
+// environment.
+func (e *Env) exists(name string) bool {
+	return fsys.Exists(e.FS(), name)
+}
+
+// Init initializes the step environment and its context state.
+func (e *Env) Init() error {
+	if err := e.initStepPath(); err != nil {
 		return err
 	}
-	return Contexts().Init()
+	return e.contexts.Init()
 }
 
-func initStepPath() error {
-	cache.Do(func() {
+func (e *Env) initStepPath() error {
+	e.once.Do(func() {
 		// Get home path from environment or from the user object.
-		homePath := os.Getenv(HomeEnv)
+		homePath := e.homePath
+		if homePath == "" {
+			homePath = os.Getenv(HomeEnv)
+		}
 		if homePath == "" {
 			usr, err := user.Current()
 			if err == nil && usr.HomeDir != "" {
 				homePath = usr.HomeDir
 			} else {
-				cache.err = fmt.Errorf("error obtaining home directory, please define environment variable %s", HomeEnv)
+				e.err = fmt.Errorf("error obtaining home directory, please define environment variable %s", HomeEnv)
 				return
 			}
 		}
 
-		// Get step path from environment or relative to home.
-		stepBasePath := os.Getenv(PathEnv)
+		// Get step path from environment, relative to home, or from the XDG
+		// base directories if they exist or are enabled. State and cache are
+		// stored in the step path, unless the XDG layout is used.
+		stepBasePath := e.stepBasePath
 		if stepBasePath == "" {
-			stepBasePath = filepath.Join(homePath, ".step")
+			stepBasePath = os.Getenv(PathEnv)
+		}
+		var statePath, cachePath string
+		legacyPath := filepath.Join(homePath, ".step")
+		xdgConfigPath, xdgStatePath, xdgCachePath := XDGPaths(homePath)
+		switch {
+		case stepBasePath != "":
+			statePath, cachePath = stepBasePath, filepath.Join(stepBasePath, "cache")
+		case e.exists(legacyPath) || !(useXDG() || e.exists(xdgConfigPath)):
+			stepBasePath = legacyPath
+			statePath, cachePath = legacyPath, filepath.Join(legacyPath, "cache")
+		default:
+			stepBasePath, statePath, cachePath = xdgConfigPath, xdgStatePath, xdgCachePath
 		}
 
-		// cleanup and add paths to cache
-		cache.homePath = filepath.Clean(homePath)
-		cache.stepBasePath = filepath.Clean(stepBasePath)
+		// cleanup and add paths to the environment
+		e.homePath = filepath.Clean(homePath)
+		e.stepBasePath = filepath.Clean(stepBasePath)
+		e.statePath = filepath.Clean(statePath)
+		e.cachePath = filepath.Clean(cachePath)
 	})
 
-	return cache.err
+	return e.err
+}
+
+// Contexts returns an object that enables context management in the
+// environment.
+func (e *Env) Contexts() *CtxState {
+	return e.contexts
+}
+
+// Home returns the user home directory of the environment.
+func (e *Env) Home() string {
+	_ = e.initStepPath()
+	return e.homePath
+}
+
+// BasePath returns the base path for the step configuration directory.
+func (e *Env) BasePath() string {
+	_ = e.initStepPath()
+	return e.stepBasePath
+}
+
+// StatePath returns the directory used to store state, like the current
+// context. It is the base path, unless the XDG layout is used.
+func (e *Env) StatePath() string {
+	_ = e.initStepPath()
+	return e.statePath
+}
+
+// CachePath returns the directory used to store cached data.
+func (e *Env) CachePath() string {
+	_ = e.initStepPath()
+	return e.cachePath
+}
+
+// Path returns the path for the step configuration directory. It returns the
+// path of the authority of the current context, or the base path if there is
+// no current context.
+func (e *Env) Path() string {
+	c := e.contexts.GetCurrent()
+	if c == nil {
+		return e.BasePath()
+	}
+	return filepath.Join(e.BasePath(), "authorities", c.Authority)
+}
+
+// ProfilePath returns the path for the currently selected profile path. It
+// returns the base path if there is no current context.
+func (e *Env) ProfilePath() string {
+	c := e.contexts.GetCurrent()
+	if c == nil {
+		return e.BasePath()
+	}
+	return filepath.Join(e.BasePath(), "profiles", c.Profile)
+}
+
+// IdentityPath returns the location of the identity directory.
+func (e *Env) IdentityPath() string {
+	return filepath.Join(e.Path(), "identity")
+}
+
+// IdentityFile returns the location of the identity file.
+func (e *Env) IdentityFile() string {
+	return filepath.Join(e.Path(), "config", "identity.json")
+}
+
+// DefaultsFile returns the location of the defaults file at the base of the
+// authority path.
+func (e *Env) DefaultsFile() string {
+	return filepath.Join(e.Path(), "config", "defaults.json")
+}
+
+// ProfileDefaultsFile returns the location of the defaults file at the base
+// of the profile path.
+func (e *Env) ProfileDefaultsFile() string {
+	return filepath.Join(e.ProfilePath(), "config", "defaults.json")
+}
+
+// ConfigPath returns the location of the $(step path)/config directory.
+func (e *Env) ConfigPath() string {
+	return filepath.Join(e.Path(), "config")
+}
+
+// ProfileConfigPath returns the location of the $(step path --profile)/config
+// directory.
+func (e *Env) ProfileConfigPath() string {
+	return filepath.Join(e.ProfilePath(), "config")
+}
+
+// CaConfigFile returns the location of the ca.json file -- configuration for
+// connecting to the CA.
+func (e *Env) CaConfigFile() string {
+	return filepath.Join(e.Path(), "config", "ca.json")
+}
+
+// ContextsFile returns the location of the config file.
+func (e *Env) ContextsFile() string {
+	return filepath.Join(e.BasePath(), "contexts.json")
+}
+
+// CurrentContextFile returns the path to the file containing the current
+// context.
+func (e *Env) CurrentContextFile() string {
+	return filepath.Join(e.StatePath(), "current-context.json")
+}
+
+// Abs returns the given path relative to the step path of the environment, see
+// the package function Abs.
+func (e *Env) Abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	// Windows accept both \ and /
+	slashed := filepath.ToSlash(path)
+	switch {
+	case strings.HasPrefix(slashed, "~/"):
+		return filepath.Join(e.Home(), path[2:])
+	case strings.HasPrefix(slashed, "./"), strings.HasPrefix(slashed, "../"):
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	default:
+		return filepath.Join(e.Path(), path)
+	}
+}
+
+// Init initializes the step environment.
+//
+// A program calling this function should fail because the step variables would
+// be undefined.
+func Init() error {
+	return defaultEnv.Init()
 }
 
 // Home returns the user home directory using the environment variable HOME or
 // the os/user package.
 func Home() string {
-	_ = initStepPath()
-	return cache.homePath
+	return defaultEnv.Home()
 }
 
 // BasePath returns the base path for the step configuration directory.
 func BasePath() string {
-	_ = initStepPath()
-	return cache.stepBasePath
+	return defaultEnv.BasePath()
+}
+
+// StatePath returns the directory used to store state, like the current
+// context.
+func StatePath() string {
+	return defaultEnv.StatePath()
+}
+
+// CachePath returns the directory used to store cached data.
+func CachePath() string {
+	return defaultEnv.CachePath()
 }
 
 // Path returns the path for the step configuration directory.
@@ -91,13 +307,10 @@ func BasePath() string {
 //     returns the path to the authority configured in the context.
 //  2. If the base step path does not have a current context configured this
 //     method returns the value defined by the environment variable STEPPATH, OR
-//  3. If no environment variable is set, this method returns `$HOME/.step`.
+//  3. If no environment variable is set, this method returns `$HOME/.step`,
+//     or `$XDG_CONFIG_HOME/step` if the XDG layout is used.
 func Path() string {
-	c := Contexts().GetCurrent()
-	if c == nil {
-		return BasePath()
-	}
-	return filepath.Join(BasePath(), "authorities", c.Authority)
+	return defaultEnv.Path()
 }
 
 // ProfilePath returns the path for the currently selected profile path.
@@ -106,61 +319,58 @@ func Path() string {
 //     returns the path to the profile configured in the context.
 //  2. If the base step path does not have a current context configured this
 //     method returns the value defined by the environment variable STEPPATH, OR
-//  3. If no environment variable is set, this method returns `$HOME/.step`.
+//  3. If no environment variable is set, this method returns `$HOME/.step`,
+//     or `$XDG_CONFIG_HOME/step` if the XDG layout is used.
 func ProfilePath() string {
-	c := Contexts().GetCurrent()
-	if c == nil {
-		return BasePath()
-	}
-	return filepath.Join(BasePath(), "profiles", c.Profile)
+	return defaultEnv.ProfilePath()
 }
 
 // IdentityPath returns the location of the identity directory.
 func IdentityPath() string {
-	return filepath.Join(Path(), "identity")
+	return defaultEnv.IdentityPath()
 }
 
 // IdentityFile returns the location of the identity file.
 func IdentityFile() string {
-	return filepath.Join(Path(), "config", "identity.json")
+	return defaultEnv.IdentityFile()
 }
 
 // DefaultsFile returns the location of the defaults file at the base of the
 // authority path.
 func DefaultsFile() string {
-	return filepath.Join(Path(), "config", "defaults.json")
+	return defaultEnv.DefaultsFile()
 }
 
 // ProfileDefaultsFile returns the location of the defaults file at the base
 // of the profile path.
 func ProfileDefaultsFile() string {
-	return filepath.Join(ProfilePath(), "config", "defaults.json")
+	return defaultEnv.ProfileDefaultsFile()
 }
 
 // ConfigPath returns the location of the $(step path)/config directory.
 func ConfigPath() string {
-	return filepath.Join(Path(), "config")
+	return defaultEnv.ConfigPath()
 }
 
 // ProfileConfigPath returns the location of the $(step path --profile)/config directory.
 func ProfileConfigPath() string {
-	return filepath.Join(ProfilePath(), "config")
+	return defaultEnv.ProfileConfigPath()
 }
 
 // CaConfigFile returns the location of the ca.json file -- configuration for
 // connecting to the CA.
 func CaConfigFile() string {
-	return filepath.Join(Path(), "config", "ca.json")
+	return defaultEnv.CaConfigFile()
 }
 
 // ContextsFile returns the location of the config file.
 func ContextsFile() string {
-	return filepath.Join(BasePath(), "contexts.json")
+	return defaultEnv.ContextsFile()
 }
 
 // CurrentContextFile returns the path to the file containing the current context.
 func CurrentContextFile() string {
-	return filepath.Join(BasePath(), "current-context.json")
+	return defaultEnv.CurrentContextFile()
 }
 
 // Abs returns the given path relative to the STEPPATH if it's not an
@@ -173,22 +383,7 @@ func CurrentContextFile() string {
 // ~/certs/root_ca.crt will be converted to '$HOME/certs/root_ca.crt'. And
 // absolute paths like '/certs/root_ca.crt' will remain the same.
 func Abs(path string) string {
-	if filepath.IsAbs(path) {
-		return path
-	}
-	// Windows accept both \ and /
-	slashed := filepath.ToSlash(path)
-	switch {
-	case strings.HasPrefix(slashed, "~/"):
-		return filepath.Join(Home(), path[2:])
-	case strings.HasPrefix(slashed, "./"), strings.HasPrefix(slashed, "../"):
-		if abs, err := filepath.Abs(path); err == nil {
-			return abs
-		}
-		return path
-	default:
-		return filepath.Join(Path(), path)
-	}
+	return defaultEnv.Abs(path)
 }
 
 // Set updates the name, version, and build time
@@ -200,13 +395,8 @@ func Set(n, v, t string) {
 
 // Version returns the current version of the binary
 func Version() string {
-	out := version
-	if version == "N/A" {
-		out = "0000000-dev"
-	}
-
 	return fmt.Sprintf("%s/%s (%s/%s)",
-		name, out, runtime.GOOS, runtime.GOARCH)
+		name, Info().Version, runtime.GOOS, runtime.GOARCH)
 }
 
 // ReleaseDate returns the time of when the binary was built
@@ -0,0 +1,145 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+func TestEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(PathEnv, "")
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".step"), 0700))
+	env1 := NewEnv(home, filepath.Join(home, "step1"))
+	env2 := NewEnv(home, "")
+
+	require.NoError(t, env1.Init())
+	require.NoError(t, env2.Init())
+
+	assert.Equal(t, home, env1.Home())
+	assert.Equal(t, filepath.Join(home, "step1"), env1.BasePath())
+	assert.Equal(t, filepath.Join(home, ".step"), env2.BasePath())
+	assert.Equal(t, filepath.Join(home, "step1", "certs", "root_ca.crt"), env1.Abs("certs/root_ca.crt"))
+	assert.Equal(t, filepath.Join(home, "certs", "root_ca.crt"), env1.Abs("~/certs/root_ca.crt"))
+
+	require.NoError(t, env1.Contexts().Add(&Context{Name: "ctx1", Authority: "ca1", Profile: "p1"}))
+	require.NoError(t, env2.Contexts().Add(&Context{Name: "ctx2", Authority: "ca2", Profile: "p2"}))
+	assert.FileExists(t, filepath.Join(home, "step1", "contexts.json"))
+	assert.FileExists(t, filepath.Join(home, ".step", "contexts.json"))
+
+	// Reload the environments from disk
+	env1, env2 = NewEnv(home, filepath.Join(home, "step1")), NewEnv(home, "")
+	require.NoError(t, env1.Init())
+	require.NoError(t, env2.Init())
+
+	_, ok := env1.Contexts().Get("ctx2")
+	assert.False(t, ok)
+	c, ok := env1.Contexts().Get("ctx1")
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(home, "step1", "authorities", "ca1"), c.Path())
+	assert.Equal(t, filepath.Join(home, "step1", "authorities", "ca1"), env1.Path())
+	assert.Equal(t, filepath.Join(home, "step1", "profiles", "p1"), env1.ProfilePath())
+	assert.Equal(t, filepath.Join(home, ".step", "authorities", "ca2", "config", "defaults.json"), env2.DefaultsFile())
+}
+
+func TestEnv_xdg(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(PathEnv, "")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv("XDG_STATE_HOME", "relative/paths/are/ignored")
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	configPath, statePath, cachePath := XDGPaths(home)
+	assert.Equal(t, filepath.Join(home, "xdg-config", "step"), configPath)
+	assert.Equal(t, filepath.Join(home, ".local", "state", "step"), statePath)
+	assert.Equal(t, filepath.Join(home, ".cache", "step"), cachePath)
+
+	// The legacy path is used by default, and the XDG layout is opt-in
+	legacyPath := filepath.Join(home, ".step")
+	env := NewEnv(home, "")
+	require.NoError(t, env.Init())
+	assert.Equal(t, legacyPath, env.BasePath())
+	t.Setenv(XDGEnv, "true")
+	env = NewEnv(home, "")
+	require.NoError(t, env.Init())
+	assert.Equal(t, configPath, env.BasePath())
+	assert.Equal(t, statePath, env.StatePath())
+	t.Setenv(XDGEnv, "")
+
+	// The legacy path is used if it exists
+	require.NoError(t, os.Mkdir(legacyPath, 0700))
+	env = NewEnv(home, "")
+	require.NoError(t, env.Init())
+	assert.Equal(t, legacyPath, env.BasePath())
+	assert.Equal(t, legacyPath, env.StatePath())
+	assert.Equal(t, filepath.Join(legacyPath, "cache"), env.CachePath())
+
+	require.NoError(t, env.Contexts().Add(&Context{Name: "ctx", Authority: "ca", Profile: "p"}))
+	require.NoError(t, os.MkdirAll(filepath.Join(legacyPath, "cache"), 0700))
+	assert.FileExists(t, filepath.Join(legacyPath, "current-context.json"))
+
+	// A failed migration is rolled back
+	cacheHome := filepath.Join(home, "cache-file")
+	require.NoError(t, os.WriteFile(cacheHome, nil, 0600))
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	assert.Error(t, env.MigrateToXDG())
+	assert.Equal(t, legacyPath, env.BasePath())
+	assert.FileExists(t, filepath.Join(legacyPath, "contexts.json"))
+	assert.FileExists(t, filepath.Join(legacyPath, "current-context.json"))
+	assert.DirExists(t, filepath.Join(legacyPath, "cache"))
+	assert.NoDirExists(t, configPath)
+	assert.NoFileExists(t, filepath.Join(statePath, "current-context.json"))
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	// Migrate to XDG
+	require.NoError(t, env.MigrateToXDG())
+	assert.NoDirExists(t, legacyPath)
+	assert.Equal(t, configPath, env.BasePath())
+	assert.Equal(t, statePath, env.StatePath())
+	assert.Equal(t, cachePath, env.CachePath())
+	assert.FileExists(t, filepath.Join(configPath, "contexts.json"))
+	assert.FileExists(t, filepath.Join(statePath, "current-context.json"))
+	assert.DirExists(t, cachePath)
+	assert.Error(t, env.MigrateToXDG())
+
+	// The XDG layout is used if the configuration directory exists
+	env = NewEnv(home, "")
+	require.NoError(t, env.Init())
+	assert.Equal(t, configPath, env.BasePath())
+	assert.Equal(t, statePath, env.StatePath())
+	if c := env.Contexts().GetCurrent(); assert.NotNil(t, c) {
+		assert.Equal(t, "ctx", c.Name)
+	}
+}
+
+func TestEnv_FS(t *testing.T) {
+	t.Setenv(PathEnv, "")
+	home := filepath.Join(t.TempDir(), "home")
+	mem := fsys.NewMemFS()
+	base := filepath.Join(home, ".step")
+	c := &Context{Name: "ctx", Authority: "ca", Profile: "p"}
+	require.NoError(t, mem.MkdirAll(filepath.Join(base, "authorities", "ca", "config"), 0700))
+	require.NoError(t, mem.WriteFile(filepath.Join(base, "authorities", "ca", "config", "defaults.json"), []byte(`{"ca-url":"https://ca.local"}`), 0600))
+
+	env := NewEnv(home, base)
+	env.SetFS(mem)
+	require.NoError(t, env.Init())
+	require.NoError(t, env.Contexts().Add(c))
+	assert.True(t, fsys.Exists(mem, env.ContextsFile()))
+	assert.NoDirExists(t, home)
+
+	// Reload the environment from memory
+	env = NewEnv(home, base)
+	env.SetFS(mem)
+	require.NoError(t, env.Init())
+	require.NoError(t, env.Contexts().SetCurrent("ctx"))
+	config, err := env.Contexts().GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ca-url": "https://ca.local"}, config)
+	assert.NoDirExists(t, home)
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -27,6 +28,16 @@ type Context struct {
 	Profile   string `json:"profile"`
 	Authority string `json:"authority"`
 	config    map[string]interface{}
+	env       *Env
+}
+
+// getEnv returns the environment of the context, or the default environment
+// if the context was not created by a context state.
+func (c *Context) getEnv() *Env {
+	if c.env == nil {
+		return defaultEnv
+	}
+	return c.env
 }
 
 // Validate validates a context and returns an error if invalid.
@@ -46,12 +57,12 @@ func (c *Context) Validate() error {
 
 // Path return the base path relative to the context.
 func (c *Context) Path() string {
-	return filepath.Join(BasePath(), "authorities", c.Authority)
+	return filepath.Join(c.getEnv().BasePath(), "authorities", c.Authority)
 }
 
 // ProfilePath return the profile base path relative to the context.
 func (c *Context) ProfilePath() string {
-	return filepath.Join(BasePath(), "profiles", c.Profile)
+	return filepath.Join(c.getEnv().BasePath(), "profiles", c.Profile)
 }
 
 // DefaultsFile returns the location of the defaults file for the context.
@@ -65,32 +76,133 @@ func (c *Context) ProfileDefaultsFile() string {
 	return filepath.Join(c.ProfilePath(), "config", "defaults.json")
 }
 
-// Load loads the configuration for the given context.
-func (c *Context) Load() error {
-	c.config = map[string]interface{}{}
-	for _, f := range []string{c.DefaultsFile(), c.ProfileDefaultsFile()} {
-		b, err := os.ReadFile(f)
+// attributesBannedFromConfig are the flags that cannot be set in the
+// configuration files.
+var attributesBannedFromConfig = []string{
+	"context",
+	"profile",
+	"authority",
+}
+
+// BannedConfigAttributes returns the names of the flags that cannot be set in
+// the configuration files.
+func BannedConfigAttributes() []string {
+	return append([]string(nil), attributesBannedFromConfig...)
+}
+
+// ProfileFile returns the location of the profile file, used to define the
+// parent of the profile.
+func (c *Context) ProfileFile() string {
+	return c.profileFile(c.Profile)
+}
+
+func (c *Context) profileFile(profile string) string {
+	return filepath.Join(c.getEnv().BasePath(), "profiles", profile, "config", "profile.json")
+}
+
+// profileConfig is the profile configuration stored in the profile file.
+type profileConfig struct {
+	Parent string `json:"parent,omitempty"`
+}
+
+// ProfileChain returns the names of the profiles used by the context, from the
+// root ancestor to the profile of the context. A profile can inherit the
+// defaults of a parent profile defining it in the profile file:
+//
+//	{"parent": "team"}
+//
+// It returns an error if a parent profile does not exist or if there is a
+// cycle.
+func (c *Context) ProfileChain() ([]string, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for name := c.Profile; name != ""; {
+		if seen[name] {
+			chain = append(chain, name)
+			return nil, errors.Errorf("profile '%s' has an inheritance cycle: %s", c.Profile, strings.Join(chain, " -> "))
+		}
+		seen[name] = true
+		chain = append(chain, name)
+
+		fn := c.profileFile(name)
+		b, err := c.getEnv().FS().ReadFile(fn)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return nil, errs.FileError(err, fn)
+		}
+		var pc profileConfig
+		if err := json.Unmarshal(b, &pc); err != nil {
+			return nil, errors.Wrapf(err, "error parsing %s", fn)
+		}
+		if pc.Parent != "" && !c.getEnv().exists(filepath.Join(c.getEnv().BasePath(), "profiles", pc.Parent)) {
+			return nil, errors.Errorf("parent profile '%s' of profile '%s' does not exist", pc.Parent, name)
+		}
+		name = pc.Parent
+	}
+
+	// Reverse the chain to start with the root ancestor.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// ProfileDefaultsFiles returns the locations of the defaults files of the
+// profiles in the profile chain, from the root ancestor to the profile of the
+// context. The files might not exist.
+func (c *Context) ProfileDefaultsFiles() ([]string, error) {
+	chain, err := c.ProfileChain()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(chain))
+	for i, profile := range chain {
+		files[i] = filepath.Join(c.getEnv().BasePath(), "profiles", profile, "config", "defaults.json")
+	}
+	return files, nil
+}
+
+// mergeDefaults reads the given defaults files, in order, and merges their
+// values; the values of a file overwrite the ones of the previous files. Files
+// that do not exist are skipped.
+func mergeDefaults(env *Env, files []string) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	for _, f := range files {
+		b, err := env.FS().ReadFile(f)
 		if os.IsNotExist(err) {
 			continue
 		} else if err != nil {
-			return errs.FileError(err, f)
+			return nil, errs.FileError(err, f)
 		}
 
 		values := make(map[string]interface{})
 		if err := json.Unmarshal(b, &values); err != nil {
-			return errors.Wrapf(err, "error parsing %s", f)
+			return nil, errors.Wrapf(err, "error parsing %s", f)
 		}
 
 		for k, v := range values {
-			c.config[k] = v
+			config[k] = v
 		}
 	}
+	return config, nil
+}
 
-	attributesBannedFromConfig := []string{
-		"context",
-		"profile",
-		"authority",
+// Load loads the configuration for the given context. The defaults of the
+// authority are overwritten by the defaults of the profiles in the profile
+// chain, see ProfileChain.
+func (c *Context) Load() error {
+	profileFiles, err := c.ProfileDefaultsFiles()
+	if err != nil {
+		return err
 	}
+
+	config, err := mergeDefaults(c.getEnv(), append([]string{c.DefaultsFile()}, profileFiles...))
+	if err != nil {
+		return err
+	}
+	c.config = config
+
 	for _, attr := range attributesBannedFromConfig {
 		if _, ok := c.config[attr]; ok {
 			ui.Printf("cannot set '%s' attribute in config files\n", attr)
@@ -114,9 +226,19 @@ type CtxState struct {
 	current  *Context
 	contexts ContextMap
 	config   map[string]interface{}
+	env      *Env
+	// envErr is the error found in the STEP_CONTEXT environment variable.
+	envErr error
 }
 
-var ctxState = &CtxState{}
+// getEnv returns the environment of the context state, or the default
+// environment if not set.
+func (cs *CtxState) getEnv() *Env {
+	if cs.env == nil {
+		return defaultEnv
+	}
+	return cs.env
+}
 
 // Init initializes the context map and current context state.
 func (cs *CtxState) Init() error {
@@ -130,29 +252,78 @@ func (cs *CtxState) Init() error {
 }
 
 func (cs *CtxState) initMap() error {
-	contextsFile := ContextsFile()
-	b, err := os.ReadFile(contextsFile)
+	contexts, err := readContextMap(cs.getEnv())
+	if err != nil {
+		return err
+	}
+	if contexts != nil {
+		cs.contexts = contexts
+	}
+	return nil
+}
+
+// readContextMap reads and validates the context map stored in the contexts
+// file of the given environment. It returns a nil map if the file does not
+// exist.
+func readContextMap(env *Env) (ContextMap, error) {
+	contextsFile := env.ContextsFile()
+	b, err := env.FS().ReadFile(contextsFile)
 	if os.IsNotExist(err) {
-		return nil
+		return nil, nil
 	} else if err != nil {
-		return errs.FileError(err, contextsFile)
+		return nil, errs.FileError(err, contextsFile)
 	}
-	cs.contexts = ContextMap{}
-	if err := json.Unmarshal(b, &cs.contexts); err != nil {
-		return errors.Wrap(err, "error unmarshaling context map")
+	contexts := ContextMap{}
+	if err := json.Unmarshal(b, &contexts); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling context map")
 	}
-	for k, ctx := range cs.contexts {
+	for k, ctx := range contexts {
 		if err := ctx.Validate(); err != nil {
-			return errors.Wrapf(err, "error in context '%s'", k)
+			return nil, errors.Wrapf(err, "error in context '%s'", k)
 		}
 		ctx.Name = k
+		ctx.env = env
 	}
-	return nil
+	return contexts, nil
+}
+
+// merge replaces the in-memory context map with the given one, read from disk
+// under the contexts lock. The current context is kept so its loaded
+// configuration is not lost.
+func (cs *CtxState) merge(contexts ContextMap) {
+	if cs.current != nil {
+		if _, ok := contexts[cs.current.Name]; ok {
+			contexts[cs.current.Name] = cs.current
+		}
+	}
+	cs.contexts = contexts
+}
+
+// writeContextMap stores the in-memory context map in the contexts file.
+func (cs *CtxState) writeContextMap() error {
+	b, err := json.MarshalIndent(cs.contexts, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cs.getEnv(), cs.getEnv().ContextsFile(), b, 0600)
 }
 
 func (cs *CtxState) initCurrent() error {
-	currentCtxFile := CurrentContextFile()
-	b, err := os.ReadFile(currentCtxFile)
+	// The environment variable takes precedence over the stored current
+	// context, and it is never persisted. It is ignored if there are no
+	// contexts. An unknown context does not make Init fail, so the commands
+	// that manage contexts can still be used; the other commands fail with
+	// the error, see ContextEnvError.
+	cs.envErr = nil
+	if name := os.Getenv(ContextEnv); name != "" && cs.Enabled() {
+		if _, ok := cs.Get(name); ok {
+			return cs.SetCurrent(name)
+		}
+		cs.envErr = errors.Errorf("context '%s' set in $%s not found", name, ContextEnv)
+	}
+
+	currentCtxFile := cs.getEnv().CurrentContextFile()
+	b, err := cs.getEnv().FS().ReadFile(currentCtxFile)
 	if os.IsNotExist(err) {
 		return nil
 	} else if err != nil {
@@ -186,10 +357,10 @@ func (cs *CtxState) load() error {
 // LoadVintage loads context configuration from the vintage (non-context) path.
 func (cs *CtxState) LoadVintage(f string) error {
 	if f == "" {
-		f = DefaultsFile()
+		f = cs.getEnv().DefaultsFile()
 	}
 
-	b, err := os.ReadFile(f)
+	b, err := cs.getEnv().FS().ReadFile(f)
 	if os.IsNotExist(err) {
 		return nil
 	} else if err != nil {
@@ -253,6 +424,14 @@ func (cs *CtxState) PromptContext() error {
 		}
 		ctxStr = items[0].Name
 	} else {
+		if !ui.IsInteractive() {
+			names := make([]string, 0, len(items))
+			for _, context := range cs.ListAlphabetical() {
+				names = append(names, context.Name)
+			}
+			return fmt.Errorf("no context selected and %w; use the '--context' flag or the %s environment variable to select one of: %s",
+				ui.ErrNoPrompt, ContextEnv, strings.Join(names, ", "))
+		}
 		i, _, err := ui.Select("Select a context for 'step':", items,
 			ui.WithSelectTemplates(ui.NamedSelectTemplates("Context")))
 		if err != nil {
@@ -266,6 +445,12 @@ func (cs *CtxState) PromptContext() error {
 	return cs.SaveCurrent(ctxStr)
 }
 
+// ContextEnvError returns the error found in the STEP_CONTEXT environment
+// variable by Init, if it names a context that does not exist, or nil.
+func (cs *CtxState) ContextEnvError() error {
+	return cs.envErr
+}
+
 // Enabled returns true if one of the following is true:
 //   - there is a current context configured
 //   - the context map is (list of available contexts) is not empty.
@@ -273,38 +458,45 @@ func (cs *CtxState) Enabled() bool {
 	return cs.current != nil || len(cs.contexts) > 0
 }
 
-// Contexts returns an object that enables context management.
+// Contexts returns an object that enables context management in the default
+// environment.
 func Contexts() *CtxState {
-	return ctxState
+	return defaultEnv.Contexts()
 }
 
 // Add adds a new context to the context map. If current context is not
 // set then store the new context as the current context for future commands.
+//
+// The contexts file is re-read while holding the contexts lock, so contexts
+// added concurrently by other processes are preserved.
 func (cs *CtxState) Add(ctx *Context) error {
 	if err := ctx.Validate(); err != nil {
 		return errors.Wrapf(err, "error adding context")
 	}
-	if cs.contexts == nil {
-		cs.contexts = map[string]*Context{ctx.Name: ctx}
-	} else {
-		cs.contexts[ctx.Name] = ctx
-	}
+	ctx.env = cs.getEnv()
 
-	b, err := json.MarshalIndent(cs.contexts, "", "    ")
+	unlock, err := lockContexts(cs.getEnv())
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	cf := ContextsFile()
-	if err := os.MkdirAll(filepath.Dir(cf), 0700); err != nil {
-		return errs.FileError(err, cf)
+	contexts, err := readContextMap(cs.getEnv())
+	if err != nil {
+		return err
 	}
-	if err := os.WriteFile(cf, b, 0600); err != nil {
-		return errs.FileError(err, cf)
+	if contexts == nil {
+		contexts = ContextMap{}
+	}
+	contexts[ctx.Name] = ctx
+	cs.merge(contexts)
+
+	if err := cs.writeContextMap(); err != nil {
+		return err
 	}
 
 	if cs.current == nil {
-		if err := cs.SaveCurrent(ctx.Name); err != nil {
+		if err := cs.saveCurrent(ctx.Name); err != nil {
 			return err
 		}
 	}
@@ -323,22 +515,32 @@ func (cs *CtxState) Get(name string) (ctx *Context, ok bool) {
 }
 
 // Remove removes a context from the context state.
+//
+// The contexts file is re-read while holding the contexts lock, so contexts
+// added concurrently by other processes are preserved.
 func (cs *CtxState) Remove(name string) error {
-	if _, ok := cs.contexts[name]; !ok {
-		return errors.Errorf("context '%s' not found", name)
-	}
 	if cs.current != nil && cs.current.Name == name {
 		return errors.New("cannot remove current context; use 'step context select' to switch contexts")
 	}
 
-	delete(cs.contexts, name)
+	unlock, err := lockContexts(cs.getEnv())
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	b, err := json.MarshalIndent(cs.contexts, "", "    ")
+	contexts, err := readContextMap(cs.getEnv())
 	if err != nil {
 		return err
 	}
+	if _, ok := contexts[name]; !ok {
+		return errors.Errorf("context '%s' not found", name)
+	}
 
-	if err := os.WriteFile(ContextsFile(), b, 0600); err != nil {
+	delete(contexts, name)
+	cs.merge(contexts)
+
+	if err := cs.writeContextMap(); err != nil {
 		return fmt.Errorf("error writing file: %w", err)
 	}
 	return nil
@@ -369,7 +571,19 @@ func (cs *CtxState) ListAlphabetical() []*Context {
 // SaveCurrent stores the given context name as the selected default context for
 // future commands.
 func (cs *CtxState) SaveCurrent(name string) error {
-	if _, ok := Contexts().Get(name); !ok {
+	unlock, err := lockContexts(cs.getEnv())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return cs.saveCurrent(name)
+}
+
+// saveCurrent stores the current context name; the caller must hold the
+// contexts lock.
+func (cs *CtxState) saveCurrent(name string) error {
+	if _, ok := cs.Get(name); !ok {
 		return errors.Errorf("context '%s' not found", name)
 	}
 
@@ -377,14 +591,17 @@ func (cs *CtxState) SaveCurrent(name string) error {
 	if err != nil {
 		return err
 	}
-	//nolint:gosec // this file does not contain sensitive info
-	if err = os.WriteFile(CurrentContextFile(), b, 0644); err != nil {
-		return errs.FileError(err, CurrentContextFile())
+	fn := cs.getEnv().CurrentContextFile()
+	if err := cs.getEnv().FS().MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return errs.FileError(err, fn)
 	}
-	return nil
+	//nolint:gosec // this file does not contain sensitive info
+	return writeFileAtomic(cs.getEnv(), fn, b, 0644)
 }
 
 // Apply the current context configuration to the command line environment.
+// Value references, like "env:NAME", are resolved before setting the flags,
+// see IsValueReference.
 func (cs *CtxState) Apply(ctx *cli.Context) error {
 	cfg, err := cs.GetConfig()
 	if err != nil {
@@ -392,7 +609,7 @@ func (cs *CtxState) Apply(ctx *cli.Context) error {
 	}
 	for _, f := range ctx.Command.Flags {
 		// Skip if EnvVar == IgnoreEnvVar
-		if getFlagEnvVar(f) == IgnoreEnvVar {
+		if FlagEnvVar(f) == IgnoreEnvVar {
 			continue
 		}
 
@@ -403,7 +620,11 @@ func (cs *CtxState) Apply(ctx *cli.Context) error {
 			}
 			// Set the flag for the first key that matches.
 			if v, ok := cfg[name]; ok {
-				ctx.Set(name, fmt.Sprintf("%v", v))
+				v, err := resolveValue(cs.getEnv(), v)
+				if err != nil {
+					return fmt.Errorf("error resolving value for flag '--%s': %w", name, err)
+				}
+				ctx.Set(name, FormatValue(v))
 				break
 			}
 		}
@@ -411,6 +632,16 @@ func (cs *CtxState) Apply(ctx *cli.Context) error {
 	return nil
 }
 
+// FormatValue returns the string representation of a configuration value, as
+// it is set in a flag. Numbers are formatted without an exponent, so a value
+// like 1000000 can be parsed by an integer flag.
+func FormatValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
 // getEnvVar generates the environment variable for the given flag name.
 func getEnvVar(name string) string {
 	parts := strings.Split(name, ",")
@@ -419,26 +650,113 @@ func getEnvVar(name string) string {
 	return "STEP_" + strings.ToUpper(name)
 }
 
-// getFlagEnvVar returns the value of the EnvVar field of a flag.
-func getFlagEnvVar(f cli.Flag) string {
+// getScopedEnvVar generates the environment variable for the given flag name in
+// the command with the given path.
+func getScopedEnvVar(path []string, name string) string {
+	scope := strings.ToUpper(strings.ReplaceAll(strings.Join(path, "_"), "-", "_"))
+	return strings.Replace(getEnvVar(name), "STEP_", "STEP_"+scope+"_", 1)
+}
+
+// EnvVarFlag is the interface that custom flag types can implement to be set
+// using environment variables and the configuration files. The flags defined
+// in the urfave/cli package, pointers to them, and other structs with an
+// EnvVar string field are supported without implementing it.
+type EnvVarFlag interface {
+	cli.Flag
+	// GetEnvVar returns the environment variables of the flag, comma
+	// separated.
+	GetEnvVar() string
+	// WithEnvVar returns the flag with the given environment variables.
+	WithEnvVar(envVar string) cli.Flag
+}
+
+// FlagEnvVar returns the value of the EnvVar field of a flag.
+func FlagEnvVar(f cli.Flag) string {
+	if ef, ok := f.(EnvVarFlag); ok {
+		return ef.GetEnvVar()
+	}
 	v := reflect.ValueOf(f)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
 	if v.Kind() == reflect.Struct {
 		envVar := v.FieldByName("EnvVar")
-		if envVar.IsValid() {
+		if envVar.IsValid() && envVar.Kind() == reflect.String {
 			return envVar.String()
 		}
 	}
 	return ""
 }
 
+// setFlagEnvVar returns the flag with the given environment variables, and
+// true if the flag supports them. The flag is copied, so a flag, or a pointer
+// to a flag, shared by several commands keeps its own environment variable in
+// each of them.
+func setFlagEnvVar(f cli.Flag, envVar string) (cli.Flag, bool) {
+	if ef, ok := f.(EnvVarFlag); ok {
+		return ef.WithEnvVar(envVar), true
+	}
+
+	v := reflect.ValueOf(f)
+	isPtr := v.Kind() == reflect.Ptr
+	if isPtr {
+		if v.IsNil() {
+			return f, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return f, false
+	}
+
+	cp := reflect.New(v.Type())
+	cp.Elem().Set(v)
+	field := cp.Elem().FieldByName("EnvVar")
+	if !field.IsValid() || !field.CanSet() || field.Kind() != reflect.String {
+		return f, false
+	}
+	field.SetString(envVar)
+	if !isPtr {
+		cp = cp.Elem()
+	}
+	if nf, ok := cp.Interface().(cli.Flag); ok {
+		return nf, true
+	}
+	return f, false
+}
+
+type envVarOptions struct {
+	scoped bool
+}
+
+// EnvVarOption is the type of the functions that modify how SetEnvVar
+// generates the environment variable names.
+type EnvVarOption func(*envVarOptions)
+
+// WithScopedEnvVars, if true, makes SetEnvVar generate environment variables
+// scoped to the command, like STEP_CA_CERTIFICATE_KTY for the flag --kty of
+// the command 'ca certificate'. The global name, STEP_KTY, is used as a
+// fallback if the scoped one is not set.
+func WithScopedEnvVars(b bool) EnvVarOption {
+	return func(o *envVarOptions) {
+		o.scoped = b
+	}
+}
+
 // SetEnvVar sets the the EnvVar element to each flag recursively.
-func SetEnvVar(c *cli.Command) {
+func SetEnvVar(c *cli.Command, opts ...EnvVarOption) {
+	o := new(envVarOptions)
+	for _, fn := range opts {
+		fn(o)
+	}
+	setEnvVar(c, nil, o)
+}
+
+func setEnvVar(c *cli.Command, parents []string, o *envVarOptions) {
 	if c == nil {
 		return
 	}
+	path := append(parents[:len(parents):len(parents)], c.Name)
 
 	// Enable getting the flags from a json file
 	if c.Before == nil && c.Action != nil {
@@ -447,78 +765,20 @@ func SetEnvVar(c *cli.Command) {
 
 	// Enable getting the flags from environment variables
 	for i := range c.Flags {
+		if FlagEnvVar(c.Flags[i]) != "" {
+			continue
+		}
 		envVar := getEnvVar(c.Flags[i].GetName())
-		switch f := c.Flags[i].(type) {
-		case cli.BoolFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.BoolTFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.DurationFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.Float64Flag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.GenericFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.Int64Flag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.IntFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.IntSliceFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.Int64SliceFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.StringFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.StringSliceFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.Uint64Flag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
-		case cli.UintFlag:
-			if f.EnvVar == "" {
-				f.EnvVar = envVar
-				c.Flags[i] = f
-			}
+		if o.scoped {
+			envVar = getScopedEnvVar(path, c.Flags[i].GetName()) + "," + envVar
+		}
+		if f, ok := setFlagEnvVar(c.Flags[i], envVar); ok {
+			c.Flags[i] = f
 		}
 	}
 
 	for i := range c.Subcommands {
-		SetEnvVar(&c.Subcommands[i])
+		setEnvVar(&c.Subcommands[i], path, o)
 	}
 }
 
@@ -527,6 +787,11 @@ func SetEnvVar(c *cli.Command) {
 //
 // TODO(mariano): right now it only supports parameters at first level.
 func getConfigVars(ctx *cli.Context) (err error) {
+	// Disable all prompts if the no-prompt flag, command.NoPromptFlag, is set.
+	if ctx.Bool("no-prompt") || ctx.GlobalBool("no-prompt") {
+		ui.SetNoPrompt(true)
+	}
+
 	if ctx.Bool("no-context") {
 		return nil
 	}
@@ -538,9 +803,17 @@ func getConfigVars(ctx *cli.Context) (err error) {
 	//    and loaded.
 	//  - If vintage context then check if overwritten by --config flag.
 	if cs.Enabled() {
+		// The STEP_CONTEXT environment variable has been resolved by Init.
+		// If it is not valid, only the commands that manage contexts can be
+		// used.
 		if ctx.IsSet("context") {
 			err = cs.SetCurrent(ctx.String("context"))
-		} else if cs.Enabled() && cs.GetCurrent() == nil {
+		} else if envErr := cs.ContextEnvError(); envErr != nil {
+			if !isContextCommand(ctx) {
+				return envErr
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", envErr)
+		} else if cs.GetCurrent() == nil {
 			err = cs.PromptContext()
 		}
 		if err != nil {
@@ -563,3 +836,10 @@ func getConfigVars(ctx *cli.Context) (err error) {
 
 	return nil
 }
+
+// isContextCommand returns true if the command manages contexts, like
+// 'step context select'.
+func isContextCommand(ctx *cli.Context) bool {
+	name := strings.Fields(strings.ToLower(ctx.Command.FullName()))
+	return len(name) > 0 && name[0] == "context"
+}
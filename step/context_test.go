@@ -3,13 +3,18 @@ package step
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+
+	"github.com/smallstep/cli-utils/ui"
 )
 
 func TestContextValidate(t *testing.T) {
@@ -86,6 +91,7 @@ type config struct {
 func TestCtxState_load(t *testing.T) {
 	contextsDir := t.TempDir()
 	t.Setenv(HomeEnv, contextsDir)
+	setTestStepPath(t, filepath.Join(contextsDir, ".step"))
 
 	ctx1ConfigDirectory := filepath.Join(contextsDir, ".step", "authorities", "ctx1", "config")
 	err := os.MkdirAll(ctx1ConfigDirectory, 0o777)
@@ -198,10 +204,10 @@ func TestCtxState_load(t *testing.T) {
 			if tt.stepPath != "" {
 				// alter the state in a non-standard way, because it's
 				// cached once.
-				currentStepPath := cache.stepBasePath
-				cache.stepBasePath = tt.stepPath
+				currentStepPath := defaultEnv.stepBasePath
+				defaultEnv.stepBasePath = tt.stepPath
 				defer func() {
-					cache.stepBasePath = currentStepPath
+					defaultEnv.stepBasePath = currentStepPath
 				}()
 			}
 
@@ -230,3 +236,297 @@ func TestCtxState_load(t *testing.T) {
 		})
 	}
 }
+
+// setTestStepPath overrides the cached step path with the given directory for
+// the duration of the test.
+func setTestStepPath(t *testing.T, dir string) {
+	t.Helper()
+	_ = defaultEnv.initStepPath()
+	oldBase, oldState, oldCache := defaultEnv.stepBasePath, defaultEnv.statePath, defaultEnv.cachePath
+	defaultEnv.stepBasePath = dir
+	defaultEnv.statePath = dir
+	defaultEnv.cachePath = filepath.Join(dir, "cache")
+	t.Cleanup(func() {
+		defaultEnv.stepBasePath, defaultEnv.statePath, defaultEnv.cachePath = oldBase, oldState, oldCache
+	})
+}
+
+func TestCtxState_Add(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	// Simulate multiple processes initialized before any of them writes.
+	states := make([]*CtxState, 10)
+	for i := range states {
+		states[i] = &CtxState{}
+		require.NoError(t, states[i].initMap())
+	}
+
+	var wg sync.WaitGroup
+	for i, cs := range states {
+		wg.Add(1)
+		go func(i int, cs *CtxState) {
+			defer wg.Done()
+			name := fmt.Sprintf("ctx%d", i)
+			assert.NoError(t, cs.Add(&Context{Name: name, Authority: name, Profile: name}))
+		}(i, cs)
+	}
+	wg.Wait()
+
+	contexts, err := readContextMap(defaultEnv)
+	require.NoError(t, err)
+	assert.Len(t, contexts, len(states))
+
+	b, err := os.ReadFile(CurrentContextFile())
+	require.NoError(t, err)
+	var sc storedCurrent
+	require.NoError(t, json.Unmarshal(b, &sc))
+	assert.Contains(t, contexts, sc.Context)
+
+	matches, err := filepath.Glob(filepath.Join(BasePath(), ".*.tmp*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestCtxState_Remove(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	cs1, cs2 := &CtxState{}, &CtxState{}
+	require.NoError(t, cs1.Add(&Context{Name: "ctx1", Authority: "ctx1", Profile: "ctx1"}))
+	require.NoError(t, cs2.initMap())
+	require.NoError(t, cs1.Add(&Context{Name: "ctx2", Authority: "ctx2", Profile: "ctx2"}))
+	require.NoError(t, cs1.SetCurrent("ctx2"))
+
+	// cs2 does not know about ctx2, but it must be kept.
+	require.NoError(t, cs2.Remove("ctx1"))
+	contexts, err := readContextMap(defaultEnv)
+	require.NoError(t, err)
+	assert.Equal(t, ContextMap{"ctx2": {Name: "ctx2", Authority: "ctx2", Profile: "ctx2", env: defaultEnv}}, contexts)
+
+	assert.EqualError(t, cs2.Remove("ctx1"), "context 'ctx1' not found")
+	assert.EqualError(t, cs1.Remove("ctx2"), "cannot remove current context; use 'step context select' to switch contexts")
+}
+
+func TestCtxState_Init_contextEnv(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	cs := &CtxState{}
+	require.NoError(t, cs.Add(&Context{Name: "ctx1", Authority: "ctx1", Profile: "ctx1"}))
+	require.NoError(t, cs.Add(&Context{Name: "ctx2", Authority: "ctx2", Profile: "ctx2"}))
+	current, err := os.ReadFile(CurrentContextFile())
+	require.NoError(t, err)
+
+	t.Setenv(ContextEnv, "ctx2")
+	cs = &CtxState{}
+	require.NoError(t, cs.Init())
+	if assert.NotNil(t, cs.GetCurrent()) {
+		assert.Equal(t, "ctx2", cs.GetCurrent().Name)
+	}
+
+	// The current context file must not be modified.
+	b, err := os.ReadFile(CurrentContextFile())
+	require.NoError(t, err)
+	assert.Equal(t, current, b)
+
+	// An unknown context does not make Init fail, and the stored current
+	// context is used.
+	t.Setenv(ContextEnv, "missing")
+	cs = &CtxState{}
+	require.NoError(t, cs.Init())
+	assert.EqualError(t, cs.ContextEnvError(), "context 'missing' set in $STEP_CONTEXT not found")
+	if assert.NotNil(t, cs.GetCurrent()) {
+		assert.Equal(t, "ctx2", cs.GetCurrent().Name)
+	}
+
+	// The variable is ignored without contexts.
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+	cs = &CtxState{}
+	require.NoError(t, cs.Init())
+	assert.NoError(t, cs.ContextEnvError())
+	assert.False(t, cs.Enabled())
+}
+
+func TestCtxState_PromptContext_noPrompt(t *testing.T) {
+	ui.SetNoPrompt(true)
+	t.Cleanup(func() { ui.SetNoPrompt(false) })
+
+	cs := &CtxState{
+		contexts: ContextMap{
+			"b": {Name: "b", Authority: "b", Profile: "b"},
+			"a": {Name: "a", Authority: "a", Profile: "a"},
+		},
+	}
+	err := cs.PromptContext()
+	assert.ErrorIs(t, err, ui.ErrNoPrompt)
+	assert.EqualError(t, err, "no context selected and cannot prompt for input in a non-interactive environment; use the '--context' flag or the STEP_CONTEXT environment variable to select one of: a, b")
+}
+
+func TestContext_Load_profileChain(t *testing.T) {
+	setTestStepPath(t, filepath.Join(t.TempDir(), ".step"))
+
+	writeJSON := func(fn string, v any) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0700))
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(fn, b, 0600))
+	}
+	profileDir := func(name string) string {
+		return filepath.Join(BasePath(), "profiles", name, "config")
+	}
+
+	c := &Context{Name: "ca", Authority: "ca", Profile: "me"}
+	writeJSON(c.DefaultsFile(), map[string]any{"ca-url": "https://ca.local", "not-after": "1h", "kty": "RSA"})
+	writeJSON(filepath.Join(profileDir("base"), "defaults.json"), map[string]any{"kty": "EC", "crv": "P-256", "not-after": "8h"})
+	writeJSON(filepath.Join(profileDir("team"), "defaults.json"), map[string]any{"not-after": "24h", "provisioner": "team"})
+	writeJSON(filepath.Join(profileDir("team"), "profile.json"), map[string]any{"parent": "base"})
+	writeJSON(filepath.Join(profileDir("me"), "defaults.json"), map[string]any{"provisioner": "me@example.com"})
+	writeJSON(filepath.Join(profileDir("me"), "profile.json"), map[string]any{"parent": "team"})
+
+	chain, err := c.ProfileChain()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base", "team", "me"}, chain)
+
+	require.NoError(t, c.Load())
+	assert.Equal(t, map[string]any{
+		"ca-url":      "https://ca.local",
+		"kty":         "EC",
+		"crv":         "P-256",
+		"not-after":   "24h",
+		"provisioner": "me@example.com",
+	}, c.config)
+
+	// Cycle
+	writeJSON(filepath.Join(profileDir("base"), "profile.json"), map[string]any{"parent": "me"})
+	assert.EqualError(t, c.Load(), "profile 'me' has an inheritance cycle: me -> team -> base -> me")
+
+	// Missing parent
+	writeJSON(filepath.Join(profileDir("base"), "profile.json"), map[string]any{"parent": "missing"})
+	assert.EqualError(t, c.Load(), "parent profile 'missing' of profile 'base' does not exist")
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", "foo", "foo"},
+		{"bool", true, "true"},
+		{"integer", 2048.0, "2048"},
+		{"large integer", 1000000.0, "1000000"},
+		{"float", 0.5, "0.5"},
+		{"small float", 0.0000001, "0.0000001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatValue(tt.value))
+		})
+	}
+}
+
+func TestSetEnvVar(t *testing.T) {
+	newCommand := func() *cli.Command {
+		return &cli.Command{
+			Name: "ca",
+			Subcommands: []cli.Command{
+				{
+					Name:   "certificate",
+					Action: func(*cli.Context) error { return nil },
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "kty"},
+						cli.BoolFlag{Name: "not-before, nbf"},
+						cli.StringFlag{Name: "password-file", EnvVar: IgnoreEnvVar},
+					},
+				},
+			},
+		}
+	}
+	envVars := func(c *cli.Command) []string {
+		var s []string
+		for _, f := range c.Subcommands[0].Flags {
+			s = append(s, FlagEnvVar(f))
+		}
+		return s
+	}
+
+	c := newCommand()
+	SetEnvVar(c)
+	assert.Equal(t, []string{"STEP_KTY", "STEP_NOT_BEFORE", IgnoreEnvVar}, envVars(c))
+	assert.NotNil(t, c.Subcommands[0].Before)
+	assert.Nil(t, c.Before)
+
+	c = newCommand()
+	SetEnvVar(c, WithScopedEnvVars(true))
+	assert.Equal(t, []string{
+		"STEP_CA_CERTIFICATE_KTY,STEP_KTY",
+		"STEP_CA_CERTIFICATE_NOT_BEFORE,STEP_NOT_BEFORE",
+		IgnoreEnvVar,
+	}, envVars(c))
+
+	// The scoped variable takes precedence over the global one.
+	t.Setenv("STEP_KTY", "RSA")
+	app := cli.NewApp()
+	app.Commands = []cli.Command{*c}
+	app.Commands[0].Subcommands[0].Before = nil
+	app.Commands[0].Subcommands[0].Action = func(ctx *cli.Context) error {
+		assert.Equal(t, "EC", ctx.String("kty"))
+		return nil
+	}
+	t.Setenv("STEP_CA_CERTIFICATE_KTY", "EC")
+	require.NoError(t, app.Run([]string{"step", "ca", "certificate"}))
+}
+
+// customFlag is a flag that implements EnvVarFlag.
+type customFlag struct {
+	cli.StringFlag
+}
+
+func (f customFlag) GetEnvVar() string { return f.StringFlag.EnvVar }
+
+func (f customFlag) WithEnvVar(envVar string) cli.Flag {
+	f.StringFlag.EnvVar = envVar
+	return f
+}
+
+// structFlag is a flag with an EnvVar field that does not implement
+// EnvVarFlag.
+type structFlag struct {
+	Name   string
+	EnvVar string
+}
+
+func (f structFlag) Apply(*flag.FlagSet) {}
+func (f structFlag) GetName() string     { return f.Name }
+func (f structFlag) String() string      { return f.Name }
+
+func TestSetEnvVar_customFlags(t *testing.T) {
+	ptr := &cli.StringFlag{Name: "ca-url"}
+	c := &cli.Command{
+		Name:   "ca",
+		Action: func(*cli.Context) error { return nil },
+		Flags: []cli.Flag{
+			customFlag{cli.StringFlag{Name: "kty"}},
+			ptr,
+			structFlag{Name: "root"},
+			&structFlag{Name: "ignored", EnvVar: IgnoreEnvVar},
+		},
+	}
+
+	SetEnvVar(c, WithScopedEnvVars(true))
+	assert.Equal(t, "STEP_CA_KTY,STEP_KTY", FlagEnvVar(c.Flags[0]))
+	assert.IsType(t, customFlag{}, c.Flags[0])
+	assert.Equal(t, "STEP_CA_CA_URL,STEP_CA_URL", FlagEnvVar(c.Flags[1]))
+	assert.IsType(t, &cli.StringFlag{}, c.Flags[1])
+	assert.Equal(t, "STEP_CA_ROOT,STEP_ROOT", FlagEnvVar(c.Flags[2]))
+	assert.Equal(t, IgnoreEnvVar, FlagEnvVar(c.Flags[3]))
+
+	// Pointers are copied, so a shared flag gets the variable of each command.
+	assert.Empty(t, ptr.EnvVar)
+	other := &cli.Command{
+		Name:   "ssh",
+		Action: func(*cli.Context) error { return nil },
+		Flags:  []cli.Flag{ptr},
+	}
+	SetEnvVar(other, WithScopedEnvVars(true))
+	assert.Equal(t, "STEP_SSH_CA_URL,STEP_CA_URL", FlagEnvVar(other.Flags[0]))
+	assert.Equal(t, "STEP_CA_CA_URL,STEP_CA_URL", FlagEnvVar(c.Flags[1]))
+}
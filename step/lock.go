@@ -0,0 +1,63 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/smallstep/cli-utils/errs"
+	"github.com/smallstep/cli-utils/fileutil/fsys"
+)
+
+// ContextsLockFile returns the location of the file used to serialize the
+// modifications of the contexts and current context files.
+func (e *Env) ContextsLockFile() string {
+	return filepath.Join(e.BasePath(), ".contexts.lock")
+}
+
+// ContextsLockFile returns the location of the file used to serialize the
+// modifications of the contexts and current context files.
+func ContextsLockFile() string {
+	return defaultEnv.ContextsLockFile()
+}
+
+// lockContexts acquires an exclusive lock on the contexts lock file of the
+// given environment, blocking until it is available. The returned function
+// releases the lock.
+//
+// A separate lock file is used because the contexts files are replaced
+// atomically on write, and a lock held on a replaced file would not be
+// visible to other processes.
+func lockContexts(env *Env) (func(), error) {
+	lf := env.ContextsLockFile()
+	if err := env.FS().MkdirAll(filepath.Dir(lf), 0700); err != nil {
+		return nil, errs.FileError(err, lf)
+	}
+	f, err := env.FS().OpenFile(lf, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errs.FileError(err, lf)
+	}
+	// Only files of the operating system can be locked.
+	osFile, ok := f.(*os.File)
+	if !ok {
+		return func() { f.Close() }, nil
+	}
+	if err := lockFile(osFile); err != nil {
+		f.Close()
+		return nil, errs.FileError(err, lf)
+	}
+	return func() {
+		_ = unlockFile(osFile)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// filename and renames it to filename, so readers will either see the old or
+// the new contents, but never a partial write. The file is written in the
+// file system of the given environment.
+func writeFileAtomic(env *Env, filename string, data []byte, perm os.FileMode) error {
+	if err := fsys.WriteFileAtomic(env.FS(), filename, data, perm); err != nil {
+		return errs.FileError(err, filename)
+	}
+	return nil
+}
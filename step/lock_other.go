@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package step
+
+import "os"
+
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
@@ -0,0 +1,17 @@
+//go:build unix
+
+package step
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX) // #nosec G115 -- uintptr comes from file descriptor
+}
+
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN) // #nosec G115 -- uintptr comes from file descriptor
+}
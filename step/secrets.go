@@ -0,0 +1,158 @@
+package step
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/errs"
+)
+
+// RedactedValue is the value used to replace sensitive values when a
+// configuration is printed.
+const RedactedValue = "[REDACTED]"
+
+// AllowExecEnv is the name of the environment variable that enables the
+// exec: references in configuration values. They are disabled by default
+// because a configuration file, for example one imported from a context
+// bundle, could run any command.
+const AllowExecEnv = "STEP_ALLOW_EXEC"
+
+// execTimeout is the maximum time an exec: reference can run.
+var execTimeout = time.Minute
+
+// isExecAllowed returns true if the exec: references are enabled.
+func isExecAllowed() bool {
+	b, err := strconv.ParseBool(os.Getenv(AllowExecEnv))
+	return err == nil && b
+}
+
+// IsValueReference returns true if the given configuration value is a
+// reference that will be resolved when the configuration is applied. The
+// supported references are:
+//
+//   - file:<path> is replaced by the contents of the file.
+//   - env:<name> is replaced by the value of the environment variable.
+//   - exec:<command> [arguments] is replaced by the output of the command.
+//     The command is not run by a shell, arguments are separated by spaces.
+//     These references are only resolved if the STEP_ALLOW_EXEC environment
+//     variable is set to true.
+//
+// Trailing newlines are removed from the resolved values.
+//
+// A literal value that starts with one of the prefixes is escaped with a
+// leading backslash: \env:name, written as "\\env:name" in a JSON file, is the
+// literal value env:name. One backslash is removed from values that start
+// with backslashes followed by a prefix, so \\env:name is \env:name.
+func IsValueReference(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && hasReferencePrefix(s)
+}
+
+// hasReferencePrefix returns true if s starts with a reference prefix.
+func hasReferencePrefix(s string) bool {
+	for _, prefix := range []string{"file:", "env:", "exec:"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeValue returns the literal value of an escaped reference, and true
+// if s is one, see IsValueReference.
+func unescapeValue(s string) (string, bool) {
+	if strings.HasPrefix(s, `\`) && hasReferencePrefix(strings.TrimLeft(s, `\`)) {
+		return s[1:], true
+	}
+	return s, false
+}
+
+// ResolveValue returns the value of the given configuration value reference.
+// Values that are not references are returned as they are.
+func ResolveValue(v interface{}) (interface{}, error) {
+	return resolveValue(defaultEnv, v)
+}
+
+// resolveValue resolves the given value reference, relative paths in file:
+// references are relative to the step path of the given environment.
+func resolveValue(env *Env, v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	if literal, ok := unescapeValue(s); ok {
+		return literal, nil
+	}
+
+	switch {
+	case strings.HasPrefix(s, "file:"):
+		fn := env.Abs(strings.TrimPrefix(s, "file:"))
+		b, err := env.FS().ReadFile(fn)
+		if err != nil {
+			return nil, errs.FileError(err, fn)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(s, "env:"):
+		name := strings.TrimPrefix(s, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, errors.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(s, "exec:"):
+		if !isExecAllowed() {
+			return nil, errors.Errorf("exec references are disabled; set %s=true to enable them", AllowExecEnv)
+		}
+		args := strings.Fields(strings.TrimPrefix(s, "exec:"))
+		if len(args) == 0 {
+			return nil, errors.New("exec reference cannot be empty")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204 -- command configured by the user
+		cmd.Stderr = os.Stderr
+		b, err := cmd.Output()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error running %s", args[0])
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	default:
+		return v, nil
+	}
+}
+
+// isSensitiveKey returns true if the given configuration key might contain a
+// secret. Flags with paths to files containing secrets are not sensitive.
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "-file") || strings.HasSuffix(key, "-path") {
+		return false
+	}
+	for _, s := range []string{"password", "secret", "token"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactConfig returns a copy of the given configuration that can be printed.
+// Literal values of keys that might contain secrets, like passwords or tokens,
+// are replaced by RedactedValue. References are kept as they are, because
+// they do not contain the secret.
+func RedactConfig(cfg map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if isSensitiveKey(k) && !IsValueReference(v) {
+			v = RedactedValue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
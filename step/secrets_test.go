@@ -0,0 +1,84 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveValue(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "password.txt")
+	require.NoError(t, os.WriteFile(fn, []byte("file-password\n"), 0600))
+	t.Setenv("STEP_TEST_PASSWORD", "env-password")
+	t.Setenv(AllowExecEnv, "true")
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"ok/literal", "password", "password", false},
+		{"ok/number", 1.0, 1.0, false},
+		{"ok/file", "file:" + fn, "file-password", false},
+		{"ok/env", "env:STEP_TEST_PASSWORD", "env-password", false},
+		{"ok/escaped", `\env:STEP_TEST_PASSWORD`, "env:STEP_TEST_PASSWORD", false},
+		{"ok/escaped-backslash", `\\exec:false`, `\exec:false`, false},
+		{"ok/backslash", `\password`, `\password`, false},
+		{"fail/file", "file:" + fn + ".missing", nil, true},
+		{"fail/env", "env:STEP_TEST_MISSING", nil, true},
+		{"fail/exec-empty", "exec: ", nil, true},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			name    string
+			value   interface{}
+			want    interface{}
+			wantErr bool
+		}{
+			{"ok/exec", "exec:echo exec-password", "exec-password", false},
+			{"fail/exec", "exec:false", nil, true},
+		}...)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveValue(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveValue_execDisabled(t *testing.T) {
+	t.Setenv(AllowExecEnv, "")
+	_, err := ResolveValue("exec:echo exec-password")
+	assert.EqualError(t, err, "exec references are disabled; set STEP_ALLOW_EXEC=true to enable them")
+}
+
+func TestRedactConfig(t *testing.T) {
+	cfg := map[string]interface{}{
+		"ca-url":               "https://ca.local",
+		"password":             "secret",
+		"provisioner-password": "env:PROVISIONER_PASSWORD",
+		"password-file":        "/run/secrets/password",
+		"token":                "eyJhbGciOi",
+		"client-secret":        `\env:literal`,
+	}
+	assert.Equal(t, map[string]interface{}{
+		"ca-url":               "https://ca.local",
+		"password":             RedactedValue,
+		"provisioner-password": "env:PROVISIONER_PASSWORD",
+		"password-file":        "/run/secrets/password",
+		"token":                RedactedValue,
+		"client-secret":        RedactedValue,
+	}, RedactConfig(cfg))
+	assert.Equal(t, "secret", cfg["password"])
+}
@@ -0,0 +1,59 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ContextExport returns the shell statement that selects the given context for
+// the current shell session using the STEP_CONTEXT environment variable. This
+// allows a command like 'step context use' to select a context without
+// modifying the current context shared by all the sessions:
+//
+//	eval "$(step context use my-context)"
+//
+// The supported shells are sh, bash, zsh, fish, powershell (or pwsh) and cmd.
+// In cmd, names with special characters, like % or &, are not supported.
+// If shell is empty, it will be detected from the SHELL environment variable.
+func ContextExport(shell, name string) (string, error) {
+	if shell == "" {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+
+	switch strings.ToLower(shell) {
+	case "", ".", "sh", "bash", "zsh", "ksh", "dash":
+		return "export " + ContextEnv + "=" + quoteSh(name), nil
+	case "fish":
+		return "set -gx " + ContextEnv + " " + quoteFish(name), nil
+	case "powershell", "pwsh":
+		return "$env:" + ContextEnv + " = '" + strings.ReplaceAll(name, "'", "''") + "'", nil
+	case "cmd":
+		// cmd expands variables even in quoted strings, and it does not have
+		// a reliable way to escape them, so the special characters are
+		// rejected.
+		if strings.ContainsAny(name, cmdSpecialChars) {
+			return "", errors.Errorf("context name %q cannot be used in cmd; it contains special characters like %%, ! or &", name)
+		}
+		return `set "` + ContextEnv + "=" + name + `"`, nil
+	default:
+		return "", errors.Errorf("unsupported shell '%s'", shell)
+	}
+}
+
+// cmdSpecialChars are the characters that cannot be safely used in a cmd set
+// statement.
+const cmdSpecialChars = "%!^&|<>\"\r\n"
+
+// quoteSh quotes s so it can be used as a single word in POSIX shells.
+func quoteSh(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes s so it can be used as a single word in the fish shell.
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
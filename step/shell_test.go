@@ -0,0 +1,38 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextExport(t *testing.T) {
+	tests := []struct {
+		shell   string
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"bash", "ca.example.com", "export STEP_CONTEXT='ca.example.com'", false},
+		{"zsh", "it's", `export STEP_CONTEXT='it'\''s'`, false},
+		{"fish", `it's\`, `set -gx STEP_CONTEXT 'it\'s\\'`, false},
+		{"pwsh", "it's", "$env:STEP_CONTEXT = 'it''s'", false},
+		{"cmd", "ca", `set "STEP_CONTEXT=ca"`, false},
+		{"cmd", "%PATH%", "", true},
+		{"cmd", "a&b", "", true},
+		{"cmd", "a^b", "", true},
+		{"cmd", `a"b`, "", true},
+		{"tcsh", "ca", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			got, err := ContextExport(tt.shell, tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/cli-utils/errs"
+)
+
+// XDGEnv defines the name of the environment variable that enables the XDG
+// layout for new installations, see XDGPaths.
+const XDGEnv = "STEP_XDG"
+
+// useXDG returns true if the XDG layout is enabled with the STEP_XDG
+// environment variable.
+func useXDG() bool {
+	b, err := strconv.ParseBool(os.Getenv(XDGEnv))
+	return err == nil && b
+}
+
+// xdgDir returns the step directory in the XDG base directory defined by the
+// given environment variable, or the default relative to the home directory.
+// Relative paths in the environment variable are ignored as required by the
+// specification.
+func xdgDir(homePath, key, def string) string {
+	dir := os.Getenv(key)
+	if dir == "" || !filepath.IsAbs(dir) {
+		dir = filepath.Join(homePath, def)
+	}
+	return filepath.Join(dir, "step")
+}
+
+// XDGPaths returns the configuration, state and cache directories of the XDG
+// layout for the given home directory. They default to ~/.config/step,
+// ~/.local/state/step and ~/.cache/step, and can be modified using the
+// environment variables XDG_CONFIG_HOME, XDG_STATE_HOME and XDG_CACHE_HOME.
+//
+// The XDG layout is used if the environment variable STEPPATH is not set, the
+// directory $HOME/.step does not exist, and either the XDG configuration
+// directory exists or the environment variable STEP_XDG is set to true. By
+// default, $HOME/.step is used.
+func XDGPaths(homePath string) (configPath, statePath, cachePath string) {
+	return xdgDir(homePath, "XDG_CONFIG_HOME", ".config"),
+		xdgDir(homePath, "XDG_STATE_HOME", filepath.Join(".local", "state")),
+		xdgDir(homePath, "XDG_CACHE_HOME", ".cache")
+}
+
+// MigrateToXDG moves the legacy $HOME/.step directory to the XDG layout: the
+// configuration to the XDG configuration directory, the current context file
+// to the XDG state directory, and the cache to the XDG cache directory. After
+// the migration the environment uses the new paths.
+//
+// It returns an error if the environment does not use $HOME/.step, or if the
+// XDG configuration directory already exists. If a move fails, the previous
+// ones are undone.
+func (e *Env) MigrateToXDG() error {
+	if err := e.initStepPath(); err != nil {
+		return err
+	}
+
+	legacyPath := filepath.Join(e.homePath, ".step")
+	if e.stepBasePath != legacyPath {
+		return errors.Errorf("cannot migrate %s: the environment does not use %s", e.stepBasePath, legacyPath)
+	}
+	configPath, statePath, cachePath := XDGPaths(e.homePath)
+	if e.exists(configPath) {
+		return errors.Errorf("cannot migrate %s: %s already exists", legacyPath, configPath)
+	}
+
+	// The moves are undone in reverse order if one fails, so the legacy
+	// layout is kept.
+	type moved struct{ oldPath, newPath string }
+	var done []moved
+	move := func(oldPath, newPath string) error {
+		if !e.exists(oldPath) {
+			return nil
+		}
+		if err := e.FS().MkdirAll(filepath.Dir(newPath), 0700); err != nil {
+			return errs.FileError(err, newPath)
+		}
+		if err := e.FS().Rename(oldPath, newPath); err != nil {
+			return errs.FileError(err, newPath)
+		}
+		done = append(done, moved{oldPath, newPath})
+		return nil
+	}
+	rollback := func(err error) error {
+		for i := len(done) - 1; i >= 0; i-- {
+			if rerr := e.FS().Rename(done[i].newPath, done[i].oldPath); rerr != nil {
+				return errors.Wrapf(err, "error restoring %s after failed migration: %v", done[i].oldPath, rerr)
+			}
+		}
+		return err
+	}
+	if err := move(legacyPath, configPath); err != nil {
+		return rollback(err)
+	}
+	if err := move(filepath.Join(configPath, "current-context.json"), filepath.Join(statePath, "current-context.json")); err != nil {
+		return rollback(err)
+	}
+	if err := move(filepath.Join(configPath, "cache"), cachePath); err != nil {
+		return rollback(err)
+	}
+
+	e.stepBasePath = configPath
+	e.statePath = statePath
+	e.cachePath = cachePath
+	return nil
+}
+
+// MigrateToXDG moves the legacy $HOME/.step directory of the default
+// environment to the XDG layout, see Env.MigrateToXDG.
+func MigrateToXDG() error {
+	return defaultEnv.MigrateToXDG()
+}
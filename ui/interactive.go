@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/chzyer/readline"
+	"github.com/pkg/errors"
+)
+
+// NoPromptEnv defines the name of the environment variable that disables all
+// the prompts.
+const NoPromptEnv = "STEP_NO_PROMPT"
+
+// CIEnv defines the name of the environment variable set by most continuous
+// integration services. Prompts are disabled if it is set to a true value, like
+// "true" or "1", as parsed by strconv.ParseBool.
+const CIEnv = "CI"
+
+// ErrNoPrompt is the error returned by the prompt functions when prompts are
+// disabled or the environment is not interactive.
+var ErrNoPrompt = errors.New("cannot prompt for input in a non-interactive environment")
+
+var noPrompt atomic.Bool
+
+// SetNoPrompt enables or disables the no-prompt mode. In no-prompt mode, every
+// prompt that does not have a value set fails with ErrNoPrompt instead of
+// waiting for user input.
+func SetNoPrompt(v bool) {
+	noPrompt.Store(v)
+}
+
+// IsInteractive returns true if prompts can be presented to the user. It
+// returns false if the no-prompt mode is enabled, using SetNoPrompt or the
+// STEP_NO_PROMPT environment variable, if the CI environment variable is set to
+// a true value, or if there is no terminal available.
+func IsInteractive() bool {
+	if noPrompt.Load() || envBool(NoPromptEnv) || envBool(CIEnv) {
+		return false
+	}
+	if readline.DefaultIsTerminal() {
+		return true
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	tty.Close()
+	return true
+}
+
+// envBool returns true if the given environment variable is set to a true
+// value.
+func envBool(key string) bool {
+	b, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && b
+}
+
+// noPromptError returns ErrNoPrompt annotated with the given prompt label.
+func noPromptError(label string) error {
+	return errors.Wrapf(ErrNoPrompt, "error running prompt '%s'", label)
+}
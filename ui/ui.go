@@ -155,6 +155,9 @@ func Prompt(label string, opts ...Option) (string, error) {
 	}
 
 	// Prompt using the terminal
+	if !IsInteractive() {
+		return "", noPromptError(label)
+	}
 	clean, err := preparePromptTerminal()
 	if err != nil {
 		return "", err
@@ -191,6 +194,9 @@ func PromptPassword(label string, opts ...Option) ([]byte, error) {
 	}
 
 	// Prompt using the terminal
+	if !IsInteractive() {
+		return nil, noPromptError(label)
+	}
 	clean, err := preparePromptTerminal()
 	if err != nil {
 		return nil, err
@@ -277,6 +283,9 @@ func Select(label string, items interface{}, opts ...Option) (int, string, error
 	}
 	o.apply(opts)
 
+	if !IsInteractive() {
+		return 0, "", noPromptError(label)
+	}
 	clean, err := prepareSelectTerminal()
 	if err != nil {
 		return 0, "", err
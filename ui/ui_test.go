@@ -3,6 +3,8 @@ package ui
 import (
 	"errors"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_promptRun(t *testing.T) {
@@ -89,3 +91,43 @@ func Test_promptRun(t *testing.T) {
 		})
 	}
 }
+
+func TestNoPrompt(t *testing.T) {
+	SetNoPrompt(true)
+	t.Cleanup(func() { SetNoPrompt(false) })
+
+	assert.False(t, IsInteractive())
+
+	_, err := Prompt("Name")
+	assert.ErrorIs(t, err, ErrNoPrompt)
+	assert.EqualError(t, err, "error running prompt 'Name': cannot prompt for input in a non-interactive environment")
+
+	_, err = PromptPassword("Password")
+	assert.ErrorIs(t, err, ErrNoPrompt)
+
+	_, _, err = Select("Select", []string{"a", "b"})
+	assert.ErrorIs(t, err, ErrNoPrompt)
+
+	// Values set do not require a prompt.
+	s, err := Prompt("Name", WithValue("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", s)
+}
+
+func TestIsInteractive_env(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"no-prompt", NoPromptEnv, "true"},
+		{"ci", CIEnv, "true"},
+		{"ci number", CIEnv, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			assert.False(t, IsInteractive())
+		})
+	}
+}